@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	entity "videoAPI/Entity"
+	service "videoAPI/Service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDKey is the context key under which middlewares.RequestID stores
+// the ID of the current request.
+const RequestIDKey = "request_id"
+
+// AuditPage is one page of audit events.
+type AuditPage struct {
+	Items   []entity.AuditEvent `json:"items"`
+	Total   int64               `json:"total"`
+	Page    int                 `json:"page"`
+	PerPage int                 `json:"per_page"`
+}
+
+// record adds an event about the current request to the audit log.
+func (c *controller) record(context *gin.Context, action, actor, target, detail string) {
+	c.audit.Record(entity.AuditEvent{
+		Action:    action,
+		Actor:     actor,
+		Target:    target,
+		IP:        context.ClientIP(),
+		UserAgent: context.Request.UserAgent(),
+		RequestID: context.GetString(RequestIDKey),
+		Detail:    detail,
+	})
+}
+
+// @Summary Query the audit log
+// @Description Search security-relevant events, newest first. Admin only.
+// @ID find-audit-events
+// @Produce json
+// @Param action query string false "Action, e.g. auth.login.failure"
+// @Param actor query string false "Actor email"
+// @Param target query string false "Target, e.g. a video ID"
+// @Param from query string false "Earliest time (RFC 3339)"
+// @Param to query string false "Latest time, exclusive (RFC 3339)"
+// @Param page query int false "Page number"
+// @Param per_page query int false "Events per page (max 100)"
+// @Success 200 {object} AuditPage
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Router /admin/audit [get]
+func (c *controller) FindAuditEvents(context *gin.Context) error {
+	filter := service.AuditFilter{
+		Action: context.Query("action"),
+		Actor:  context.Query("actor"),
+		Target: context.Query("target"),
+	}
+
+	var err error
+	if filter.From, err = queryTime(context, "from"); err != nil {
+		return err
+	}
+	if filter.To, err = queryTime(context, "to"); err != nil {
+		return err
+	}
+
+	page, err := strconv.Atoi(context.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		return service.Validation("invalid_page", "Page must be a positive number")
+	}
+	perPage, err := strconv.Atoi(context.DefaultQuery("per_page", "20"))
+	if err != nil || perPage < 1 || perPage > 100 {
+		return service.Validation("invalid_per_page", "per_page must be between 1 and 100")
+	}
+
+	events, total, err := c.audit.Query(filter, (page-1)*perPage, perPage)
+	if err != nil {
+		return err
+	}
+
+	context.JSON(http.StatusOK, AuditPage{Items: events, Total: total, Page: page, PerPage: perPage})
+
+	return nil
+}
+
+func queryTime(context *gin.Context, name string) (time.Time, error) {
+	value := context.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, service.Validation("invalid_"+name, name+" must be an RFC 3339 time")
+	}
+	return parsed, nil
+}
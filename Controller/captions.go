@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	entity "videoAPI/Entity"
+	service "videoAPI/Service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// @Summary List caption tracks
+// @Description List the caption tracks of a video, one per language
+// @ID list-captions
+// @Produce json
+// @Param id path string true "Video ID"
+// @Success 200 {array} entity.CaptionTrack
+// @Failure 404 {object} Problem
+// @Router /videos/{id}/captions [get]
+func (c *controller) ListCaptions(context *gin.Context) error {
+	video, err := c.viewableVideo(context)
+	if err != nil {
+		return err
+	}
+
+	tracks := video.Captions
+	if tracks == nil {
+		tracks = []entity.CaptionTrack{}
+	}
+	context.JSON(http.StatusOK, tracks)
+	return nil
+}
+
+// @Summary Get a caption track
+// @Description Serve the caption track of a video in a language as WebVTT
+// @ID get-captions
+// @Produce text/vtt
+// @Param id path string true "Video ID"
+// @Param language path string true "BCP 47 language tag"
+// @Success 200 {string} string "WebVTT captions"
+// @Success 304
+// @Failure 404 {object} Problem
+// @Router /videos/{id}/captions/{language} [get]
+func (c *controller) GetCaptions(context *gin.Context) error {
+	video, err := c.viewableVideo(context)
+	if err != nil {
+		return err
+	}
+
+	file, info, err := c.service.OpenCaptions(video, context.Param("language"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	context.Header("Content-Type", info.ContentType)
+	if info.ETag != "" {
+		context.Header("ETag", info.ETag)
+	}
+	if video.Visibility == entity.VisibilityPrivate {
+		context.Header("Cache-Control", "private")
+	}
+	http.ServeContent(context.Writer, context.Request, "", info.ModTime, file)
+	return nil
+}
+
+// @Summary Upload a caption track
+// @Description Add or replace the caption track of a video in the language named in the path. The body is SRT or WebVTT; it is stored as WebVTT.
+// @ID put-captions
+// @Accept text/vtt
+// @Accept application/x-subrip
+// @Produce json
+// @Param id path string true "Video ID"
+// @Param language path string true "BCP 47 language tag"
+// @Param label query string false "Name of the track shown to viewers"
+// @Param If-Match header string false "ETag the video must still have"
+// @Success 200 {object} entity.Video
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 412 {object} Problem
+// @Failure 413 {object} Problem
+// @Router /videos/{id}/captions/{language} [put]
+func (c *controller) PutCaptions(context *gin.Context) error {
+	id := context.Param("id")
+	existingVideo, err := c.editableVideo(context, id)
+	if err != nil {
+		return err
+	}
+
+	track := entity.CaptionTrack{Language: context.Param("language"), Label: context.Query("label")}
+	if err := binding.Validator.ValidateStruct(track); err != nil {
+		return invalidBody(context, err)
+	}
+
+	version, err := expectedVersion(context, existingVideo)
+	if err != nil {
+		return err
+	}
+
+	context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, service.MaxCaptionBytes)
+	video, err := c.service.PutCaptions(id, version, track, context.Request.Body)
+	if err != nil {
+		return tooLarge(err, service.ErrCaptionsTooLarge)
+	}
+
+	context.Header("ETag", etag(video))
+	context.JSON(http.StatusOK, video)
+	return nil
+}
+
+// @Summary Delete a caption track
+// @Description Remove the caption track of a video in a language
+// @ID delete-captions
+// @Produce json
+// @Param id path string true "Video ID"
+// @Param language path string true "BCP 47 language tag"
+// @Param If-Match header string false "ETag the video must still have"
+// @Success 200 {object} entity.Video
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 412 {object} Problem
+// @Router /videos/{id}/captions/{language} [delete]
+func (c *controller) DeleteCaptions(context *gin.Context) error {
+	id := context.Param("id")
+	existingVideo, err := c.editableVideo(context, id)
+	if err != nil {
+		return err
+	}
+
+	version, err := expectedVersion(context, existingVideo)
+	if err != nil {
+		return err
+	}
+
+	video, err := c.service.DeleteCaptions(id, version, context.Param("language"))
+	if err != nil {
+		return err
+	}
+
+	context.Header("ETag", etag(video))
+	context.JSON(http.StatusOK, video)
+	return nil
+}
+
+// @Summary Search captions
+// @Description Find the cues of public videos whose caption text matches q. Each hit carries the video and the time the cue is shown.
+// @ID search-captions
+// @Produce json
+// @Param q query string true "Words to find"
+// @Param limit query int false "Hits to return (max 100)"
+// @Success 200 {array} entity.CaptionHit
+// @Failure 400 {object} Problem
+// @Router /videos/captions [get]
+func (c *controller) SearchCaptions(context *gin.Context) error {
+	limit, err := strconv.Atoi(context.DefaultQuery("limit", strconv.Itoa(service.DefaultCaptionHits)))
+	if err != nil {
+		return service.Validation("invalid_limit", "limit must be a number")
+	}
+
+	hits, err := c.service.SearchCaptions(context.Query("q"), limit)
+	if err != nil {
+		return err
+	}
+
+	context.JSON(http.StatusOK, hits)
+	return nil
+}
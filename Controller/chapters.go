@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	entity "videoAPI/Entity"
+	service "videoAPI/Service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// ChaptersRequest sets the chapters of a video.
+type ChaptersRequest struct {
+	Chapters []entity.Chapter `json:"chapters" binding:"dive"`
+}
+
+// @Summary List chapters
+// @Description List the chapters of a video: those set on it, or else those read from timestamp lines such as "00:00 Intro" in its description
+// @ID list-chapters
+// @Produce json
+// @Param id path string true "Video ID"
+// @Success 200 {array} entity.Chapter
+// @Failure 404 {object} Problem
+// @Router /videos/{id}/chapters [get]
+func (c *controller) ListChapters(context *gin.Context) error {
+	video, err := c.viewableVideo(context)
+	if err != nil {
+		return err
+	}
+
+	context.JSON(http.StatusOK, service.Chapters(video))
+	return nil
+}
+
+// @Summary Set chapters
+// @Description Replace the chapters of a video. Chapters must be in order of start and start before the end of the video. An empty list clears them, so they are read from the description again.
+// @ID put-chapters
+// @Accept json
+// @Produce json
+// @Param id path string true "Video ID"
+// @Param chapters body ChaptersRequest true "Chapters"
+// @Param If-Match header string false "ETag the video must still have"
+// @Success 200 {object} entity.Video
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 412 {object} Problem
+// @Router /videos/{id}/chapters [put]
+func (c *controller) PutChapters(context *gin.Context) error {
+	var request ChaptersRequest
+	if err := json.NewDecoder(context.Request.Body).Decode(&request); err != nil {
+		return invalidBody(context, err)
+	}
+	if err := binding.Validator.ValidateStruct(request); err != nil {
+		return invalidBody(context, err)
+	}
+
+	return c.setChapters(context, request.Chapters)
+}
+
+// @Summary Clear chapters
+// @Description Remove the chapters set on a video, so they are read from its description again
+// @ID delete-chapters
+// @Produce json
+// @Param id path string true "Video ID"
+// @Param If-Match header string false "ETag the video must still have"
+// @Success 200 {object} entity.Video
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 412 {object} Problem
+// @Router /videos/{id}/chapters [delete]
+func (c *controller) DeleteChapters(context *gin.Context) error {
+	return c.setChapters(context, []entity.Chapter{})
+}
+
+func (c *controller) setChapters(context *gin.Context, chapters []entity.Chapter) error {
+	id := context.Param("id")
+	existingVideo, err := c.editableVideo(context, id)
+	if err != nil {
+		return err
+	}
+
+	version, err := expectedVersion(context, existingVideo)
+	if err != nil {
+		return err
+	}
+
+	video, err := c.service.PutChapters(id, version, chapters)
+	if err != nil {
+		return err
+	}
+
+	context.Header("ETag", etag(video))
+	context.JSON(http.StatusOK, video)
+	return nil
+}
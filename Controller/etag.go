@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	entity "videoAPI/Entity"
+	service "videoAPI/Service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag is the strong entity tag of a video version.
+func etag(video entity.Video) string {
+	return `"` + strconv.FormatInt(video.Version, 10) + `"`
+}
+
+// etagList splits an If-Match or If-None-Match header into its tags, weak
+// ones keeping their W/ prefix.
+func etagList(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// expectedVersion returns the version a write must apply to. Without an
+// If-Match header this is the version that was just read; otherwise it is the
+// version named by the header, so the storage layer rather than a possibly
+// stale cache decides whether it still matches. If-Match uses the strong
+// comparison (RFC 7232 section 3.1), so weak tags never match.
+func expectedVersion(context *gin.Context, current entity.Video) (int64, error) {
+	header := context.GetHeader("If-Match")
+	if header == "" {
+		return current.Version, nil
+	}
+
+	for _, tag := range etagList(header) {
+		if tag == "*" || tag == etag(current) {
+			return current.Version, nil
+		}
+	}
+	for _, tag := range etagList(header) {
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		version, err := strconv.ParseInt(strings.Trim(tag, `"`), 10, 64)
+		if err == nil {
+			return version, nil
+		}
+	}
+
+	return 0, service.ErrVersionMismatch
+}
+
+// notModified reports whether the If-None-Match header matches video, in
+// which case a 304 has been written. If-None-Match uses the weak comparison,
+// so W/ tags match too.
+func notModified(context *gin.Context, video entity.Video) bool {
+	for _, tag := range etagList(context.GetHeader("If-None-Match")) {
+		if tag = strings.TrimPrefix(tag, "W/"); tag == "*" || tag == etag(video) {
+			context.Header("ETag", etag(video))
+			context.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
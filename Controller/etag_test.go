@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	entity "videoAPI/Entity"
+	service "videoAPI/Service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func conditionalContext(header, value string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(w)
+	context.Request = httptest.NewRequest("PATCH", "/videos/1", nil)
+	if header != "" {
+		context.Request.Header.Set(header, value)
+	}
+	return context, w
+}
+
+func TestExpectedVersion(t *testing.T) {
+	current := entity.Video{ID: "1", Version: 3}
+
+	context, _ := conditionalContext("", "")
+	version, err := expectedVersion(context, current)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), version)
+
+	context, _ = conditionalContext("If-Match", `"4"`)
+	version, err = expectedVersion(context, current)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), version)
+
+	context, _ = conditionalContext("If-Match", `*`)
+	version, _ = expectedVersion(context, current)
+	assert.Equal(t, int64(3), version)
+
+	context, _ = conditionalContext("If-Match", `"garbage"`)
+	_, err = expectedVersion(context, current)
+	assert.ErrorIs(t, err, service.ErrVersionMismatch)
+
+	context, _ = conditionalContext("If-Match", `W/"3"`)
+	_, err = expectedVersion(context, current)
+	assert.ErrorIs(t, err, service.ErrVersionMismatch, "weak tags fail the strong comparison")
+
+	context, _ = conditionalContext("If-Match", `W/"3", "5"`)
+	version, err = expectedVersion(context, current)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), version)
+}
+
+func TestNotModified(t *testing.T) {
+	video := entity.Video{ID: "1", Version: 2}
+
+	context, _ := conditionalContext("If-None-Match", `"1"`)
+	assert.False(t, notModified(context, video))
+
+	context, w := conditionalContext("If-None-Match", `"1", W/"2"`)
+	assert.True(t, notModified(context, video))
+	context.Writer.WriteHeaderNow()
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+}
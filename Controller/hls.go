@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	entity "videoAPI/Entity"
+	service "videoAPI/Service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// @Summary Register an HLS rendition
+// @Description Add or replace the rendition of a video named in the path. Its segment files must already be stored under videos/{id}/hls/{name}/.
+// @ID put-rendition
+// @Accept json
+// @Produce json
+// @Param id path string true "Video ID"
+// @Param name path string true "Rendition name, such as 720p"
+// @Param rendition body entity.Rendition true "Rendition"
+// @Param If-Match header string false "ETag the video must still have"
+// @Success 200 {object} entity.Video
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 412 {object} Problem
+// @Router /videos/{id}/renditions/{name} [put]
+func (c *controller) PutRendition(context *gin.Context) error {
+	id := context.Param("id")
+	existingVideo, err := c.editableVideo(context, id)
+	if err != nil {
+		return err
+	}
+
+	var rendition entity.Rendition
+	if err := json.NewDecoder(context.Request.Body).Decode(&rendition); err != nil {
+		return invalidBody(context, err)
+	}
+	rendition.Name = context.Param("name")
+	if err := binding.Validator.ValidateStruct(rendition); err != nil {
+		return invalidBody(context, err)
+	}
+
+	version, err := expectedVersion(context, existingVideo)
+	if err != nil {
+		return err
+	}
+
+	video, err := c.service.PutRendition(id, version, rendition)
+	if err != nil {
+		return err
+	}
+
+	context.Header("ETag", etag(video))
+	context.JSON(http.StatusOK, video)
+	return nil
+}
+
+// viewableVideo finds the video of the request, hiding it from users who may
+// not view it.
+func (c *controller) viewableVideo(context *gin.Context) (entity.Video, error) {
+	video, err := c.service.FindByID(context.Param("id"))
+	if err != nil {
+		return entity.Video{}, err
+	}
+	if !canView(context, video) {
+		return entity.Video{}, service.ErrVideoNotFound
+	}
+	return video, nil
+}
+
+func writePlaylist(context *gin.Context, video entity.Video, playlist string) {
+	context.Header("ETag", etag(video))
+	if video.Visibility == entity.VisibilityPrivate {
+		context.Header("Cache-Control", "private")
+	}
+	context.Data(http.StatusOK, service.PlaylistContentType, []byte(playlist))
+}
+
+// @Summary Get the HLS master playlist
+// @Description List the renditions of a video as an HLS variant playlist
+// @ID master-playlist
+// @Produce application/vnd.apple.mpegurl
+// @Param id path string true "Video ID"
+// @Success 200 {string} string "Master playlist"
+// @Success 304
+// @Failure 404 {object} Problem
+// @Router /videos/{id}/hls/master.m3u8 [get]
+func (c *controller) MasterPlaylist(context *gin.Context) error {
+	video, err := c.viewableVideo(context)
+	if err != nil {
+		return err
+	}
+	if len(video.Renditions) == 0 {
+		return service.ErrRenditionNotFound
+	}
+	if notModified(context, video) {
+		return nil
+	}
+
+	writePlaylist(context, video, service.MasterPlaylist(video.Renditions))
+	return nil
+}
+
+// @Summary Get an HLS media playlist or segment
+// @Description Serve index.m3u8, the media playlist of a rendition, or one of its segments
+// @ID hls-file
+// @Produce application/vnd.apple.mpegurl
+// @Produce video/mp2t
+// @Param id path string true "Video ID"
+// @Param rendition path string true "Rendition name"
+// @Param file path string true "index.m3u8 or a segment file"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Success 304
+// @Failure 404 {object} Problem
+// @Router /videos/{id}/hls/{rendition}/{file} [get]
+func (c *controller) HLSFile(context *gin.Context) error {
+	video, err := c.viewableVideo(context)
+	if err != nil {
+		return err
+	}
+	name, file := context.Param("rendition"), context.Param("file")
+
+	if file == "index.m3u8" {
+		rendition, err := service.FindRendition(video, name)
+		if err != nil {
+			return err
+		}
+		if notModified(context, video) {
+			return nil
+		}
+		writePlaylist(context, video, service.MediaPlaylist(rendition))
+		return nil
+	}
+
+	segment, info, err := c.service.OpenSegment(video, name, file)
+	if err != nil {
+		return err
+	}
+	defer segment.Close()
+
+	context.Header("Content-Type", info.ContentType)
+	if info.ETag != "" {
+		context.Header("ETag", info.ETag)
+	}
+	if video.Visibility == entity.VisibilityPrivate {
+		context.Header("Cache-Control", "private")
+	}
+	http.ServeContent(context.Writer, context.Request, "", info.ModTime, segment)
+	return nil
+}
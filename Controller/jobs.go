@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	entity "videoAPI/Entity"
+	service "videoAPI/Service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobList is the jobs in one state, with how many jobs are in each.
+type JobList struct {
+	Counts map[string]int64 `json:"counts"`
+	Jobs   []entity.Job     `json:"jobs"`
+}
+
+// @Summary List background jobs
+// @Description List the jobs in a state, with the number of jobs in every state. Admin only.
+// @ID list-jobs
+// @Produce json
+// @Param state query string false "ready, scheduled, running or dead (default dead)"
+// @Param limit query int false "Jobs to return (max 100)"
+// @Success 200 {object} JobList
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Router /admin/jobs [get]
+func (c *controller) ListJobs(context *gin.Context) error {
+	state := context.DefaultQuery("state", entity.JobDead)
+	known := false
+	for _, s := range service.JobStates {
+		known = known || s == state
+	}
+	if !known {
+		return service.Validation("invalid_state", "state must be one of ready, scheduled, running, dead")
+	}
+
+	limit, err := strconv.Atoi(context.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		return service.Validation("invalid_limit", "limit must be between 1 and 100")
+	}
+
+	jobs, err := c.jobs.List(state, limit)
+	if err != nil {
+		return service.Internal(err)
+	}
+	counts, err := c.jobs.Counts()
+	if err != nil {
+		return service.Internal(err)
+	}
+
+	context.JSON(http.StatusOK, JobList{Counts: counts, Jobs: jobs})
+	return nil
+}
+
+// @Summary Get a background job
+// @Description Admin only.
+// @ID get-job
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} entity.Job
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Router /admin/jobs/{id} [get]
+func (c *controller) FindJob(context *gin.Context) error {
+	job, err := c.jobs.Get(context.Param("id"))
+	if err != nil {
+		return service.AsError(err)
+	}
+
+	context.JSON(http.StatusOK, job)
+	return nil
+}
+
+// @Summary Retry a background job
+// @Description Run a dead or scheduled job as soon as a worker is free. A dead job gets all its attempts again. Admin only.
+// @ID retry-job
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} entity.Job
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem
+// @Router /admin/jobs/{id}/retry [post]
+func (c *controller) RetryJob(context *gin.Context) error {
+	job, err := c.jobs.Retry(context.Param("id"), time.Now().UTC())
+	if err != nil {
+		return service.AsError(err)
+	}
+	c.record(context, entity.AuditJobRetry, CurrentUser(context), job.ID, job.Type)
+
+	context.JSON(http.StatusOK, job)
+	return nil
+}
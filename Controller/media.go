@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+
+	entity "videoAPI/Entity"
+	service "videoAPI/Service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead allows for the boundaries and headers around the file
+// part of an upload.
+const multipartOverhead = 1 << 20
+
+// absoluteURL resolves path against the URL the request was made to, so
+// stored links survive being copied off this server.
+func absoluteURL(context *gin.Context, path string) string {
+	scheme := "http"
+	if context.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := context.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + context.Request.Host + path
+}
+
+// @Summary Upload a video file
+// @Description Store the video file in the "file" part of a multipart form and point the video's URL at its stream. The file type is detected from its content; only video files are accepted.
+// @ID upload-media
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Video ID"
+// @Param file formData file true "Video file"
+// @Param If-Match header string false "ETag the video must still have"
+// @Success 200 {object} entity.Video
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 412 {object} Problem
+// @Failure 413 {object} Problem
+// @Failure 415 {object} Problem
+// @Router /videos/{id}/media [post]
+func (c *controller) UploadMedia(context *gin.Context) error {
+	id := context.Param("id")
+	existingVideo, err := c.editableVideo(context, id)
+	if err != nil {
+		return err
+	}
+
+	version, err := expectedVersion(context, existingVideo)
+	if err != nil {
+		return err
+	}
+
+	part, err := filePart(context, service.MaxMediaBytes, service.ErrMediaTooLarge)
+	if err != nil {
+		return err
+	}
+
+	video, err := c.service.UploadMedia(id, version, part, absoluteURL(context, "/videos/"+id+"/stream"), CurrentUser(context))
+	if err != nil {
+		return tooLarge(err, service.ErrMediaTooLarge)
+	}
+
+	context.Header("ETag", etag(video))
+	context.JSON(http.StatusOK, video)
+	return nil
+}
+
+// filePart returns the "file" part of a multipart upload of at most limit
+// bytes, reporting a larger one with limitErr.
+func filePart(context *gin.Context, limit int64, limitErr error) (*multipart.Part, error) {
+	context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, limit+multipartOverhead)
+	reader, err := context.Request.MultipartReader()
+	if err != nil {
+		return nil, service.Unsupported("multipart_required", "Files are uploaded as multipart/form-data")
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, service.Validation("missing_file", "Upload has no file part")
+		}
+		if err != nil {
+			if err := tooLarge(err, limitErr); err == limitErr {
+				return nil, err
+			}
+			return nil, service.Validation("invalid_upload", "Upload is not a valid multipart form")
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+	}
+}
+
+// tooLarge reports a body cut off by the size limit as limitErr rather than
+// as a failure of the server.
+func tooLarge(err, limitErr error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return limitErr
+	}
+	return err
+}
+
+// canView reports whether the requesting user may see video. Private videos
+// are only visible to their owner and to admins; unlisted ones to anyone
+// with the link.
+func canView(context *gin.Context, video entity.Video) bool {
+	return video.Visibility != entity.VisibilityPrivate || canEdit(context, video)
+}
+
+// canEdit reports whether the requesting user may change the files and
+// server-set fields of video: only its owner and admins may.
+func canEdit(context *gin.Context, video entity.Video) bool {
+	user := CurrentUser(context)
+	return (user != "" && user == video.Owner) || CurrentRole(context) == service.RoleAdmin
+}
+
+// editableVideo looks up a video for a change by its owner or an admin.
+// Others are told it does not exist if they cannot see it, and that they may
+// not change it otherwise.
+func (c *controller) editableVideo(context *gin.Context, id string) (entity.Video, error) {
+	video, err := c.service.FindByID(id)
+	if err != nil {
+		return entity.Video{}, err
+	}
+	if err := mayEdit(context, video, service.ErrVideoNotFound); err != nil {
+		return entity.Video{}, err
+	}
+	return video, nil
+}
+
+// mayEdit fails unless the requesting user may change video, with missing
+// if they cannot see it either.
+func mayEdit(context *gin.Context, video entity.Video, missing error) error {
+	if !canView(context, video) {
+		return missing
+	}
+	if !canEdit(context, video) {
+		return service.ErrNotVideoOwner
+	}
+	return nil
+}
+
+// @Summary Stream a video file
+// @Description Serve the uploaded file of a video. Range requests are answered with 206 Partial Content so players can seek. Private videos are only served to their owner and to admins.
+// @ID stream-video
+// @Produce octet-stream
+// @Param id path string true "Video ID"
+// @Param Range header string false "Byte range, such as bytes=0-1023"
+// @Param If-None-Match header string false "ETag of a cached copy"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Success 304
+// @Failure 404 {object} Problem
+// @Failure 416 {string} string "Range not satisfiable"
+// @Router /videos/{id}/stream [get]
+func (c *controller) Stream(context *gin.Context) error {
+	file, video, err := c.service.OpenMedia(context.Param("id"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if !canView(context, video) {
+		return service.ErrVideoNotFound
+	}
+
+	// Every upload is stored under a new key, so the key names the content
+	context.Header("ETag", `"`+path.Base(video.Media.Key)+`"`)
+	context.Header("Content-Type", video.Media.ContentType)
+	if video.Visibility == entity.VisibilityPrivate {
+		context.Header("Cache-Control", "private")
+	}
+
+	http.ServeContent(context.Writer, context.Request, "", video.Media.UploadedAt, file)
+	return nil
+}
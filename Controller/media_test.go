@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	entity "videoAPI/Entity"
+	service "videoAPI/Service"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func userContext(email, role string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	context, _ := gin.CreateTestContext(httptest.NewRecorder())
+	context.Request = httptest.NewRequest("GET", "/videos/1/stream", nil)
+	if email != "" {
+		context.Set("user", jwt.MapClaims{"email": email, "role": role})
+	}
+	return context
+}
+
+func TestCanView(t *testing.T) {
+	private := entity.Video{Owner: "a@b.c", Visibility: entity.VisibilityPrivate}
+	unlisted := entity.Video{Owner: "a@b.c", Visibility: entity.VisibilityUnlisted}
+
+	assert.True(t, canView(userContext("", ""), unlisted))
+	assert.False(t, canView(userContext("", ""), private))
+	assert.False(t, canView(userContext("x@y.z", ""), private))
+	assert.True(t, canView(userContext("a@b.c", ""), private))
+	assert.True(t, canView(userContext("x@y.z", service.RoleAdmin), private))
+	assert.False(t, canView(userContext("", ""), entity.Video{Visibility: entity.VisibilityPrivate}))
+}
+
+func TestCanEdit(t *testing.T) {
+	public := entity.Video{Owner: "a@b.c", Visibility: entity.VisibilityPublic}
+
+	assert.False(t, canEdit(userContext("", ""), public))
+	assert.False(t, canEdit(userContext("x@y.z", ""), public))
+	assert.True(t, canEdit(userContext("a@b.c", ""), public))
+	assert.True(t, canEdit(userContext("x@y.z", service.RoleAdmin), public))
+	assert.False(t, canEdit(userContext("", ""), entity.Video{}))
+}
+
+func TestMayEdit(t *testing.T) {
+	private := entity.Video{Owner: "a@b.c", Visibility: entity.VisibilityPrivate}
+	public := entity.Video{Owner: "a@b.c", Visibility: entity.VisibilityPublic}
+
+	assert.ErrorIs(t, mayEdit(userContext("", ""), private, service.ErrVideoNotFound), service.ErrVideoNotFound)
+	assert.ErrorIs(t, mayEdit(userContext("x@y.z", ""), private, service.ErrVideoNotInTrash), service.ErrVideoNotInTrash)
+	assert.ErrorIs(t, mayEdit(userContext("x@y.z", ""), public, service.ErrVideoNotFound), service.ErrNotVideoOwner)
+	assert.NoError(t, mayEdit(userContext("a@b.c", ""), private, service.ErrVideoNotFound))
+	assert.NoError(t, mayEdit(userContext("x@y.z", service.RoleAdmin), private, service.ErrVideoNotFound))
+}
+
+func TestAbsoluteURL(t *testing.T) {
+	context := userContext("", "")
+	context.Request.Host = "videos.example.com"
+	assert.Equal(t, "http://videos.example.com/videos/1/stream", absoluteURL(context, "/videos/1/stream"))
+
+	context.Request.Header.Set("X-Forwarded-Proto", "https")
+	assert.Equal(t, "https://videos.example.com/videos/1/stream", absoluteURL(context, "/videos/1/stream"))
+}
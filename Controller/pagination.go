@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	entity "videoAPI/Entity"
+	service "videoAPI/Service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VideoPage is the envelope of a video listing, with links to the
+// neighbouring pages.
+type VideoPage struct {
+	entity.SearchPage
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+func newVideoPage(context *gin.Context, page entity.SearchPage) VideoPage {
+	videoPage := VideoPage{SearchPage: page}
+
+	if page.HasMore {
+		if page.NextCursor != "" {
+			videoPage.Next = pageLink(context, map[string]string{"cursor": page.NextCursor, "page": ""})
+		} else {
+			videoPage.Next = pageLink(context, map[string]string{"page": strconv.Itoa(page.Page + 1)})
+		}
+	}
+	if page.Page > 1 {
+		videoPage.Prev = pageLink(context, map[string]string{"page": strconv.Itoa(page.Page - 1), "cursor": ""})
+	}
+
+	return videoPage
+}
+
+// pageLink returns the current request URL with params replaced; an empty
+// value removes the parameter.
+func pageLink(context *gin.Context, params map[string]string) string {
+	link := *context.Request.URL
+	query := link.Query()
+	for name, value := range params {
+		if value == "" {
+			query.Del(name)
+		} else {
+			query.Set(name, value)
+		}
+	}
+	link.RawQuery = query.Encode()
+	return link.RequestURI()
+}
+
+// parseVideoFilter reads the listing filters from the query string,
+// reporting every malformed parameter at once. Only admins list videos that
+// are not public, other than their own.
+func parseVideoFilter(context *gin.Context) (service.VideoFilter, error) {
+	filter := service.VideoFilter{
+		Tag:        context.Query("tag"),
+		Category:   context.Query("category"),
+		Owner:      context.Query("owner"),
+		Language:   context.Query("language"),
+		Visibility: context.Query("visibility"),
+		LinkState:  context.Query("link_status"),
+		ListedOnly: CurrentRole(context) != service.RoleAdmin,
+		Viewer:     CurrentUser(context),
+	}
+
+	var fields []service.FieldError
+	parseTime := func(name string, into *time.Time) {
+		if value := context.Query(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				fields = append(fields, service.FieldError{Field: name, Rule: "datetime", Message: name + " must be an RFC 3339 time"})
+				return
+			}
+			*into = parsed
+		}
+	}
+	parseNumber := func(name string, into **float64) {
+		if value := context.Query(name); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				fields = append(fields, service.FieldError{Field: name, Rule: "number", Message: name + " must be a number"})
+				return
+			}
+			*into = &parsed
+		}
+	}
+
+	parseTime("created_after", &filter.CreatedAfter)
+	parseTime("created_before", &filter.CreatedBefore)
+	parseNumber("duration_lt", &filter.DurationLT)
+	parseNumber("duration_gt", &filter.DurationGT)
+
+	switch filter.Visibility {
+	case "", entity.VisibilityPublic, entity.VisibilityUnlisted, entity.VisibilityPrivate:
+	default:
+		fields = append(fields, service.FieldError{Field: "visibility", Rule: "oneof", Message: "visibility must be one of public, unlisted, private"})
+	}
+	switch filter.LinkState {
+	case "", entity.LinkOK, entity.LinkDead:
+	default:
+		fields = append(fields, service.FieldError{Field: "link_status", Rule: "oneof", Message: "link_status must be one of ok, dead"})
+	}
+
+	if len(fields) > 0 {
+		return service.VideoFilter{}, service.InvalidFields(fields)
+	}
+	if filter.Visibility == entity.VisibilityPrivate && filter.Viewer == "" {
+		return service.VideoFilter{}, errPrivateListing
+	}
+	return filter, nil
+}
+
+var errPrivateListing = service.Forbidden("private_listing", "Private videos are only listed for their owner and admins")
+
+// listable keeps the videos that may be listed to the requesting user:
+// public ones, the user's own, and all of them for admins.
+func listable(context *gin.Context, videos []entity.Video) []entity.Video {
+	listed := []entity.Video{}
+	for _, video := range videos {
+		if video.Visibility == entity.VisibilityPublic || canEdit(context, video) {
+			listed = append(listed, video)
+		}
+	}
+	return listed
+}
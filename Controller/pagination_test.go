@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	entity "videoAPI/Entity"
+	service "videoAPI/Service"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func listingContext(target string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	context, _ := gin.CreateTestContext(httptest.NewRecorder())
+	context.Request = httptest.NewRequest("GET", target, nil)
+	return context
+}
+
+func TestNewVideoPageLinks(t *testing.T) {
+	context := listingContext("/videos?q=go&page=2&per_page=5")
+
+	page := newVideoPage(context, entity.SearchPage{Page: 2, PerPage: 5, HasMore: true})
+
+	assert.Equal(t, "/videos?page=3&per_page=5&q=go", page.Next)
+	assert.Equal(t, "/videos?page=1&per_page=5&q=go", page.Prev)
+}
+
+func TestNewVideoPageCursorLinks(t *testing.T) {
+	context := listingContext("/videos?page=1&per_page=5")
+
+	page := newVideoPage(context, entity.SearchPage{Page: 1, PerPage: 5, HasMore: true, NextCursor: "abc"})
+	assert.Equal(t, "/videos?cursor=abc&per_page=5", page.Next)
+	assert.Empty(t, page.Prev)
+
+	page = newVideoPage(context, entity.SearchPage{Page: 1, PerPage: 5})
+	assert.Empty(t, page.Next)
+}
+
+func TestParseVideoFilterLimitsListings(t *testing.T) {
+	filter, err := parseVideoFilter(listingContext("/videos"))
+	assert.NoError(t, err)
+	assert.True(t, filter.ListedOnly)
+	assert.Empty(t, filter.Viewer)
+
+	_, err = parseVideoFilter(listingContext("/videos?visibility=private"))
+	assert.ErrorIs(t, err, errPrivateListing)
+
+	context := listingContext("/videos?visibility=private")
+	context.Set("user", jwt.MapClaims{"email": "a@b.c"})
+	filter, err = parseVideoFilter(context)
+	assert.NoError(t, err)
+	assert.Equal(t, service.VideoFilter{Visibility: entity.VisibilityPrivate, ListedOnly: true, Viewer: "a@b.c"}, filter)
+
+	context = listingContext("/videos?visibility=private")
+	context.Set("user", jwt.MapClaims{"email": "x@y.z", "role": service.RoleAdmin})
+	filter, err = parseVideoFilter(context)
+	assert.NoError(t, err)
+	assert.False(t, filter.ListedOnly)
+}
+
+func TestListable(t *testing.T) {
+	videos := []entity.Video{
+		{ID: "1", Owner: "a@b.c", Visibility: entity.VisibilityPublic},
+		{ID: "2", Owner: "a@b.c", Visibility: entity.VisibilityPrivate},
+		{ID: "3", Owner: "x@y.z", Visibility: entity.VisibilityUnlisted},
+	}
+
+	assert.Equal(t, videos[:1], listable(userContext("", ""), videos))
+	assert.Equal(t, videos[:2], listable(userContext("a@b.c", ""), videos))
+	assert.Equal(t, videos, listable(userContext("q@r.s", service.RoleAdmin), videos))
+}
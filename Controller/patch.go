@@ -0,0 +1,360 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	entity "videoAPI/Entity"
+	service "videoAPI/Service"
+)
+
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+var editableFields = func() map[string]bool {
+	fields := map[string]bool{}
+	for _, field := range entity.EditableVideoFields {
+		fields[field] = true
+	}
+	return fields
+}()
+
+// editableDocument returns the editable fields of video as a generic JSON
+// document, ready to have a patch applied to it.
+func editableDocument(video entity.Video) (map[string]interface{}, error) {
+	raw, err := json.Marshal(video)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	for field := range doc {
+		if !editableFields[field] {
+			delete(doc, field)
+		}
+	}
+	return doc, nil
+}
+
+// patchedVideo decodes a patched document back onto a copy of existing. Any
+// field outside entity.EditableVideoFields is reported rather than silently
+// dropped.
+func patchedVideo(existing entity.Video, doc interface{}) (entity.Video, error) {
+	object, ok := doc.(map[string]interface{})
+	if !ok {
+		return entity.Video{}, service.Validation("invalid_patch", "Patch must leave the video a JSON object")
+	}
+
+	var fields []service.FieldError
+	for field := range object {
+		if editableFields[field] {
+			continue
+		}
+		rule, message := "unknown", field+" is not a video field"
+		if _, known := videoJSONFields[field]; known {
+			rule, message = "readonly", field+" cannot be changed"
+		}
+		fields = append(fields, service.FieldError{Field: field, Rule: rule, Message: message})
+	}
+	if len(fields) > 0 {
+		return entity.Video{}, service.InvalidFields(fields)
+	}
+
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return entity.Video{}, service.Internal(err)
+	}
+
+	var patched entity.Video
+	if err := json.Unmarshal(raw, &patched); err != nil {
+		return entity.Video{}, err
+	}
+	if existing.Media != nil && patched.Duration != existing.Duration {
+		return entity.Video{}, service.InvalidFields([]service.FieldError{{
+			Field:   "duration",
+			Rule:    "readonly",
+			Message: "duration is read from the uploaded file and cannot be changed",
+		}})
+	}
+	return withServerFields(patched, existing), nil
+}
+
+// withServerFields copies the server-owned fields of existing onto video.
+// Once a file has been uploaded its duration is owned by the server too.
+func withServerFields(video, existing entity.Video) entity.Video {
+	video.ID = existing.ID
+	video.Owner = existing.Owner
+	video.CreatedAt = existing.CreatedAt
+	video.UpdatedAt = existing.UpdatedAt
+	video.Version = existing.Version
+	video.Views = existing.Views
+	video.Media = existing.Media
+	video.Renditions = existing.Renditions
+	video.MediaInfo = existing.MediaInfo
+	video.Poster = existing.Poster
+	video.Captions = existing.Captions
+	video.Chapters = existing.Chapters
+	video.LinkStatus = existing.LinkStatus
+	if existing.Media != nil {
+		video.Duration = existing.Duration
+	}
+	video.SchemaVersion = existing.SchemaVersion
+	return video
+}
+
+var videoJSONFields = func() map[string]struct{} {
+	fields := map[string]struct{}{}
+	videoType := reflect.TypeOf(entity.Video{})
+	for i := 0; i < videoType.NumField(); i++ {
+		if name := jsonFieldName(videoType.Field(i)); name != "" {
+			fields[name] = struct{}{}
+		}
+	}
+	return fields
+}()
+
+// applyMergePatch applies an RFC 7396 JSON Merge Patch to target. Objects in
+// target are modified in place.
+func applyMergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = applyMergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// patchOperation is a single RFC 6902 JSON Patch operation.
+type patchOperation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+var errPatchTestFailed = service.Conflict("patch_test_failed", "JSON Patch test operation failed")
+
+func invalidPatch(format string, args ...interface{}) error {
+	return service.Validation("invalid_patch", fmt.Sprintf(format, args...))
+}
+
+// applyJSONPatch applies the operations in order. The document is modified
+// in place; on error it should be discarded.
+func applyJSONPatch(doc interface{}, operations []patchOperation) (interface{}, error) {
+	for i, op := range operations {
+		var value interface{}
+		if op.Value != nil {
+			if err := json.Unmarshal(*op.Value, &value); err != nil {
+				return nil, invalidPatch("operation %d: invalid value", i)
+			}
+		}
+
+		var err error
+		switch op.Op {
+		case "add":
+			if op.Value == nil {
+				return nil, invalidPatch("operation %d: add requires a value", i)
+			}
+			doc, err = pointerAdd(doc, op.Path, value)
+		case "remove":
+			doc, _, err = pointerRemove(doc, op.Path)
+		case "replace":
+			if op.Value == nil {
+				return nil, invalidPatch("operation %d: replace requires a value", i)
+			}
+			if doc, _, err = pointerRemove(doc, op.Path); err == nil {
+				doc, err = pointerAdd(doc, op.Path, value)
+			}
+		case "move":
+			var moved interface{}
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				return nil, invalidPatch("operation %d: cannot move a value into itself", i)
+			}
+			if doc, moved, err = pointerRemove(doc, op.From); err == nil {
+				doc, err = pointerAdd(doc, op.Path, moved)
+			}
+		case "copy":
+			var copied interface{}
+			if copied, err = pointerGet(doc, op.From); err == nil {
+				doc, err = pointerAdd(doc, op.Path, deepCopy(copied))
+			}
+		case "test":
+			var actual interface{}
+			if actual, err = pointerGet(doc, op.Path); err == nil && !reflect.DeepEqual(actual, value) {
+				return nil, errPatchTestFailed
+			}
+		default:
+			return nil, invalidPatch("operation %d: unknown op %q", i, op.Op)
+		}
+		if err != nil {
+			return nil, invalidPatch("operation %d: %v", i, err)
+		}
+	}
+	return doc, nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return length, nil
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if index > length || (!allowEnd && index == length) {
+		return 0, fmt.Errorf("array index %d out of range", index)
+	}
+	return index, nil
+}
+
+func pointerGet(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path %q does not exist", pointer)
+			}
+			doc = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[index]
+		default:
+			return nil, fmt.Errorf("path %q does not exist", pointer)
+		}
+	}
+	return doc, nil
+}
+
+// pointerAdd returns doc with value added at pointer. Arrays are rebuilt,
+// so the returned document must replace the one passed in.
+func pointerAdd(doc interface{}, pointer string, value interface{}) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := pointerGet(doc, parentPointer)
+	if err != nil {
+		return nil, err
+	}
+
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+		return doc, nil
+	case []interface{}:
+		index, err := arrayIndex(last, len(node), true)
+		if err != nil {
+			return nil, err
+		}
+		grown := append(node[:index:index], append([]interface{}{value}, node[index:]...)...)
+		return pointerSet(doc, parentPointer, grown)
+	default:
+		return nil, fmt.Errorf("path %q does not exist", pointer)
+	}
+}
+
+// pointerRemove returns doc without the value at pointer, and that value.
+func pointerRemove(doc interface{}, pointer string) (interface{}, interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, doc, nil
+	}
+
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := pointerGet(doc, parentPointer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		removed, ok := node[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("path %q does not exist", pointer)
+		}
+		delete(node, last)
+		return doc, removed, nil
+	case []interface{}:
+		index, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		removed := node[index]
+		shrunk := append(node[:index:index], node[index+1:]...)
+		doc, err = pointerSet(doc, parentPointer, shrunk)
+		return doc, removed, err
+	default:
+		return nil, nil, fmt.Errorf("path %q does not exist", pointer)
+	}
+}
+
+// pointerSet replaces the existing value at pointer.
+func pointerSet(doc interface{}, pointer string, value interface{}) (interface{}, error) {
+	if pointer == "" {
+		return value, nil
+	}
+	doc, _, err := pointerRemove(doc, pointer)
+	if err != nil {
+		return nil, err
+	}
+	return pointerAdd(doc, pointer, value)
+}
+
+func deepCopy(value interface{}) interface{} {
+	raw, _ := json.Marshal(value)
+	var copied interface{}
+	json.Unmarshal(raw, &copied)
+	return copied
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	entity "videoAPI/Entity"
+	service "videoAPI/Service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decode(t *testing.T, raw string) interface{} {
+	var value interface{}
+	assert.NoError(t, json.Unmarshal([]byte(raw), &value))
+	return value
+}
+
+func operations(t *testing.T, raw string) []patchOperation {
+	var ops []patchOperation
+	assert.NoError(t, json.Unmarshal([]byte(raw), &ops))
+	return ops
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	target := decode(t, `{"title":"old","tags":["a"],"category":"music"}`)
+	patch := decode(t, `{"title":"new","category":null,"tags":["b","c"]}`)
+
+	assert.Equal(t, decode(t, `{"title":"new","tags":["b","c"]}`), applyMergePatch(target, patch))
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	doc := decode(t, `{"title":"old","tags":["a","b"]}`)
+	ops := operations(t, `[
+		{"op":"test","path":"/title","value":"old"},
+		{"op":"replace","path":"/title","value":"new"},
+		{"op":"add","path":"/tags/-","value":"c"},
+		{"op":"remove","path":"/tags/0"},
+		{"op":"copy","from":"/title","path":"/description"},
+		{"op":"move","from":"/description","path":"/category"}
+	]`)
+
+	patched, err := applyJSONPatch(doc, ops)
+	assert.NoError(t, err)
+	assert.Equal(t, decode(t, `{"title":"new","tags":["b","c"],"category":"new"}`), patched)
+}
+
+func TestApplyJSONPatchErrors(t *testing.T) {
+	_, err := applyJSONPatch(decode(t, `{"title":"old"}`), operations(t, `[{"op":"test","path":"/title","value":"other"}]`))
+	assert.ErrorIs(t, err, errPatchTestFailed)
+
+	_, err = applyJSONPatch(decode(t, `{"title":"old"}`), operations(t, `[{"op":"remove","path":"/missing"}]`))
+	assert.Equal(t, "invalid_patch", err.(*service.Error).Code)
+
+	_, err = applyJSONPatch(decode(t, `{"tags":[]}`), operations(t, `[{"op":"add","path":"/tags/01","value":"x"}]`))
+	assert.Error(t, err)
+}
+
+func TestPatchedVideoRejectsServerFields(t *testing.T) {
+	existing := entity.Video{ID: "1", Title: "old", URL: "https://example.com", Owner: "a@b.c", Version: 4}
+	doc, err := editableDocument(existing)
+	assert.NoError(t, err)
+	assert.NotContains(t, doc, "id")
+
+	_, err = patchedVideo(existing, applyMergePatch(doc, decode(t, `{"id":"2","foo":1}`)))
+	assert.Equal(t, map[string]string{"id": "readonly", "foo": "unknown"}, fieldRules(err.(*service.Error)))
+
+	doc, _ = editableDocument(existing)
+	video, err := patchedVideo(existing, applyMergePatch(doc, decode(t, `{"title":"new"}`)))
+	assert.NoError(t, err)
+	assert.Equal(t, "new", video.Title)
+	assert.Equal(t, "1", video.ID)
+	assert.Equal(t, "a@b.c", video.Owner)
+	assert.Equal(t, int64(4), video.Version)
+}
+
+func TestPatchedVideoKeepsProbedDuration(t *testing.T) {
+	existing := entity.Video{ID: "1", Title: "old", Duration: 12.5, Media: &entity.Media{Key: "k"}}
+
+	doc, err := editableDocument(existing)
+	assert.NoError(t, err)
+	patched, err := patchedVideo(existing, applyMergePatch(doc, decode(t, `{"title":"new"}`)))
+	assert.NoError(t, err)
+	assert.Equal(t, 12.5, patched.Duration)
+
+	doc, _ = editableDocument(existing)
+	_, err = patchedVideo(existing, applyMergePatch(doc, decode(t, `{"duration":99}`)))
+	assert.Equal(t, []service.FieldError{{Field: "duration", Rule: "readonly", Message: "duration is read from the uploaded file and cannot be changed"}}, err.(*service.Error).Fields)
+
+	assert.Equal(t, 12.5, withServerFields(entity.Video{Title: "put"}, existing).Duration)
+	assert.Equal(t, 99.0, withServerFields(entity.Video{Duration: 99}, entity.Video{Duration: 12.5}).Duration)
+}
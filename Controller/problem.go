@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	service "videoAPI/Service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body. Code is the stable error code
+// of the underlying service error; Errors lists invalid fields, if any.
+type Problem struct {
+	Type     string               `json:"type"`
+	Title    string               `json:"title"`
+	Status   int                  `json:"status"`
+	Detail   string               `json:"detail,omitempty"`
+	Instance string               `json:"instance,omitempty"`
+	Code     string               `json:"code"`
+	Errors   []service.FieldError `json:"errors,omitempty"`
+}
+
+var kindStatus = map[service.Kind]int{
+	service.KindNotFound:     http.StatusNotFound,
+	service.KindConflict:     http.StatusConflict,
+	service.KindValidation:   http.StatusBadRequest,
+	service.KindUnauthorized: http.StatusUnauthorized,
+	service.KindForbidden:    http.StatusForbidden,
+	service.KindPrecondition: http.StatusPreconditionFailed,
+	service.KindUnsupported:  http.StatusUnsupportedMediaType,
+	service.KindTooLarge:     http.StatusRequestEntityTooLarge,
+	service.KindInternal:     http.StatusInternalServerError,
+}
+
+// NewProblem maps err to a problem body. Internal errors are reduced to a
+// generic message so that storage errors never reach the client.
+func NewProblem(err error, instance string) Problem {
+	domainErr := service.AsError(err)
+
+	status, ok := kindStatus[domainErr.Kind]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	return Problem{
+		Type:     "/problems/" + domainErr.Code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   domainErr.Message,
+		Instance: instance,
+		Code:     domainErr.Code,
+		Errors:   domainErr.Fields,
+	}
+}
+
+// WriteProblem writes err as an application/problem+json response.
+func WriteProblem(context *gin.Context, err error) {
+	problem := NewProblem(err, context.Request.URL.Path)
+	if problem.Status == http.StatusInternalServerError {
+		fmt.Printf("Internal error on %s %s: %v\n", context.Request.Method, context.Request.URL.Path, err)
+	}
+
+	body, _ := json.Marshal(problem)
+	context.Data(problem.Status, ProblemContentType, body)
+}
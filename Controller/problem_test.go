@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	service "videoAPI/Service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProblemMapsKinds(t *testing.T) {
+	problem := NewProblem(service.ErrVideoNotFound, "/videos/1")
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Equal(t, "video_not_found", problem.Code)
+	assert.Equal(t, "/videos/1", problem.Instance)
+
+	problem = NewProblem(service.ErrVideoExists, "")
+	assert.Equal(t, http.StatusConflict, problem.Status)
+}
+
+func TestNewProblemHidesInternalErrors(t *testing.T) {
+	problem := NewProblem(errors.New("connection refused: mongodb://localhost"), "")
+
+	assert.Equal(t, http.StatusInternalServerError, problem.Status)
+	assert.Equal(t, "internal_error", problem.Code)
+	assert.NotContains(t, problem.Detail, "mongodb")
+}
+
+func TestWriteProblem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(w)
+	context.Request = httptest.NewRequest("GET", "/videos/1", nil)
+
+	WriteProblem(context, service.ErrVideoNotFound)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"code":"video_not_found"`)
+}
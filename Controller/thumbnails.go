@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	entity "videoAPI/Entity"
+	service "videoAPI/Service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// thumbnailMaxAge is how long, in seconds, shared caches may keep a
+// thumbnail before checking its ETag again.
+const thumbnailMaxAge = 3600
+
+// @Summary Upload a poster image
+// @Description Resize the JPEG, PNG or WebP image in the "file" part of a multipart form to each configured thumbnail size and point the video's thumbnail URL at it
+// @ID upload-poster
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Video ID"
+// @Param file formData file true "Poster image"
+// @Param If-Match header string false "ETag the video must still have"
+// @Success 200 {object} entity.Video
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 412 {object} Problem
+// @Failure 413 {object} Problem
+// @Failure 415 {object} Problem
+// @Router /videos/{id}/thumbnail [post]
+func (c *controller) UploadPoster(context *gin.Context) error {
+	id := context.Param("id")
+	existingVideo, err := c.editableVideo(context, id)
+	if err != nil {
+		return err
+	}
+
+	version, err := expectedVersion(context, existingVideo)
+	if err != nil {
+		return err
+	}
+
+	part, err := filePart(context, service.MaxPosterBytes, service.ErrPosterTooLarge)
+	if err != nil {
+		return err
+	}
+
+	video, err := c.service.UploadPoster(id, version, part, absoluteURL(context, "/videos/"+id+"/thumbnail"), CurrentUser(context))
+	if err != nil {
+		return tooLarge(err, service.ErrPosterTooLarge)
+	}
+
+	context.Header("ETag", etag(video))
+	context.JSON(http.StatusOK, video)
+	return nil
+}
+
+// @Summary Get a thumbnail
+// @Description Serve the poster of a video resized to the given width, or the largest size when none is given
+// @ID get-thumbnail
+// @Produce jpeg
+// @Param id path string true "Video ID"
+// @Param size query int false "Width in pixels, one of the video's poster sizes"
+// @Param If-None-Match header string false "ETag of a cached copy"
+// @Success 200 {file} file
+// @Success 304
+// @Failure 400 {object} Problem
+// @Failure 404 {object} Problem
+// @Router /videos/{id}/thumbnail [get]
+func (c *controller) Thumbnail(context *gin.Context) error {
+	video, err := c.viewableVideo(context)
+	if err != nil {
+		return err
+	}
+
+	size := 0
+	if raw := context.Query("size"); raw != "" {
+		if size, err = strconv.Atoi(raw); err != nil || size < 1 {
+			return service.Validation("invalid_size", "size must be a positive number of pixels")
+		}
+	}
+
+	file, info, err := c.service.OpenThumbnail(video, size)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	context.Header("Content-Type", info.ContentType)
+	if info.ETag != "" {
+		context.Header("ETag", info.ETag)
+	}
+	if video.Visibility == entity.VisibilityPrivate {
+		context.Header("Cache-Control", "private")
+	} else {
+		context.Header("Cache-Control", "public, max-age="+strconv.Itoa(thumbnailMaxAge))
+	}
+	http.ServeContent(context.Writer, context.Request, "", info.ModTime, file)
+	return nil
+}
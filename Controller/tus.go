@@ -0,0 +1,216 @@
+package controller
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+
+	service "videoAPI/Service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The tus 1.0 resumable upload protocol, with the creation, expiration and
+// termination extensions. See https://tus.io/protocols/resumable-upload.
+const (
+	TusResumable      = "1.0.0"
+	TusExtensions     = "creation,expiration,termination"
+	OffsetContentType = "application/offset+octet-stream"
+)
+
+var errTusVersion = service.Precondition("tus_version_unsupported", "Tus-Resumable must be "+TusResumable)
+
+// tusRequest sets the headers every tus response carries and checks the
+// client speaks a supported protocol version.
+func tusRequest(context *gin.Context) error {
+	context.Header("Tus-Resumable", TusResumable)
+	if context.GetHeader("Tus-Resumable") != TusResumable {
+		context.Header("Tus-Version", TusResumable)
+		return errTusVersion
+	}
+	return nil
+}
+
+// parseUploadMetadata decodes an Upload-Metadata header: comma-separated
+// pairs of a key and an optional base64 value.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, service.Validation("invalid_upload_metadata", "Upload-Metadata pairs are a key and a base64 value")
+		}
+
+		value := ""
+		if len(fields) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, service.Validation("invalid_upload_metadata", "Upload-Metadata value of "+fields[0]+" is not base64")
+			}
+			value = string(decoded)
+		}
+		metadata[fields[0]] = value
+	}
+	return metadata, nil
+}
+
+func uploadHeaders(context *gin.Context, offset, length int64, expires string) {
+	context.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+	context.Header("Upload-Length", strconv.FormatInt(length, 10))
+	context.Header("Upload-Expires", expires)
+	context.Header("Cache-Control", "no-store")
+}
+
+// @Summary Describe the upload server
+// @Description Report the tus version, extensions and maximum size supported
+// @ID upload-options
+// @Success 204
+// @Router /uploads [options]
+func (c *controller) UploadOptions(context *gin.Context) error {
+	context.Header("Tus-Resumable", TusResumable)
+	context.Header("Tus-Version", TusResumable)
+	context.Header("Tus-Extension", TusExtensions)
+	context.Header("Tus-Max-Size", strconv.FormatInt(service.MaxMediaBytes, 10))
+	context.Status(http.StatusNoContent)
+	return nil
+}
+
+// @Summary Start a resumable upload
+// @Description Create a tus upload for the video named by video_id in Upload-Metadata. Once all Upload-Length bytes have been sent, the file is attached to the video.
+// @ID create-upload
+// @Param Tus-Resumable header string true "1.0.0"
+// @Param Upload-Length header int true "Size of the file in bytes"
+// @Param Upload-Metadata header string true "video_id and other keys, each followed by a base64 value"
+// @Success 201
+// @Header 201 {string} Location "URL of the upload"
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 412 {object} Problem
+// @Failure 413 {object} Problem
+// @Router /uploads [post]
+func (c *controller) CreateUpload(context *gin.Context) error {
+	if err := tusRequest(context); err != nil {
+		return err
+	}
+
+	length, err := strconv.ParseInt(context.GetHeader("Upload-Length"), 10, 64)
+	if err != nil {
+		return service.Validation("invalid_upload_length", "Upload-Length must be a positive number")
+	}
+	metadata, err := parseUploadMetadata(context.GetHeader("Upload-Metadata"))
+	if err != nil {
+		return err
+	}
+	videoID := metadata["video_id"]
+	if videoID == "" {
+		return service.Validation("missing_video_id", "Upload-Metadata must name the video_id to attach the file to")
+	}
+	if _, err := c.editableVideo(context, videoID); err != nil {
+		return err
+	}
+
+	upload, err := c.service.CreateUpload(videoID, length, metadata, CurrentUser(context))
+	if err != nil {
+		return err
+	}
+
+	context.Header("Location", absoluteURL(context, "/uploads/"+upload.ID))
+	context.Header("Upload-Expires", upload.ExpiresAt.Format(http.TimeFormat))
+	context.Status(http.StatusCreated)
+	return nil
+}
+
+// @Summary Get the offset of an upload
+// @Description Report how many bytes of a tus upload have been received, so a client can resume it
+// @ID find-upload
+// @Param id path string true "Upload ID"
+// @Param Tus-Resumable header string true "1.0.0"
+// @Success 200
+// @Header 200 {int} Upload-Offset "Bytes received"
+// @Failure 404 {object} Problem
+// @Router /uploads/{id} [head]
+func (c *controller) FindUpload(context *gin.Context) error {
+	if err := tusRequest(context); err != nil {
+		return err
+	}
+
+	upload, err := c.service.FindUpload(context.Param("id"), CurrentUser(context))
+	if err != nil {
+		return err
+	}
+
+	uploadHeaders(context, upload.Offset, upload.Length, upload.ExpiresAt.Format(http.TimeFormat))
+	context.Status(http.StatusOK)
+	return nil
+}
+
+// @Summary Send part of an upload
+// @Description Append the body to a tus upload at Upload-Offset, which must equal the bytes received so far
+// @ID write-upload
+// @Accept application/offset+octet-stream
+// @Param id path string true "Upload ID"
+// @Param Tus-Resumable header string true "1.0.0"
+// @Param Upload-Offset header int true "Bytes received so far"
+// @Success 204
+// @Header 204 {int} Upload-Offset "Bytes received"
+// @Failure 400 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 413 {object} Problem
+// @Failure 415 {object} Problem
+// @Router /uploads/{id} [patch]
+func (c *controller) WriteUpload(context *gin.Context) error {
+	if err := tusRequest(context); err != nil {
+		return err
+	}
+	if context.ContentType() != OffsetContentType {
+		return service.Unsupported("unsupported_content_type", "Upload parts are sent as "+OffsetContentType)
+	}
+	offset, err := strconv.ParseInt(context.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return service.Validation("invalid_upload_offset", "Upload-Offset must be a non-negative number")
+	}
+
+	id, owner := context.Param("id"), CurrentUser(context)
+	upload, err := c.service.FindUpload(id, owner)
+	if err != nil {
+		return err
+	}
+
+	mediaURL := absoluteURL(context, "/videos/"+upload.VideoID+"/stream")
+	upload, err = c.service.WriteUpload(id, owner, offset, context.Request.Body, mediaURL)
+	if err != nil {
+		return err
+	}
+
+	context.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	context.Header("Upload-Expires", upload.ExpiresAt.Format(http.TimeFormat))
+	context.Status(http.StatusNoContent)
+	return nil
+}
+
+// @Summary Abandon an upload
+// @Description Terminate a tus upload and discard the bytes received
+// @ID delete-upload
+// @Param id path string true "Upload ID"
+// @Param Tus-Resumable header string true "1.0.0"
+// @Success 204
+// @Failure 404 {object} Problem
+// @Router /uploads/{id} [delete]
+func (c *controller) DeleteUpload(context *gin.Context) error {
+	if err := tusRequest(context); err != nil {
+		return err
+	}
+
+	if err := c.service.DeleteUpload(context.Param("id"), CurrentUser(context)); err != nil {
+		return err
+	}
+
+	context.Status(http.StatusNoContent)
+	return nil
+}
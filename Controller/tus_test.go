@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	service "videoAPI/Service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUploadMetadata(t *testing.T) {
+	metadata, err := parseUploadMetadata("video_id MTIz,filename dHJpcC5tcDQ=, is_draft")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"video_id": "123", "filename": "trip.mp4", "is_draft": ""}, metadata)
+
+	_, err = parseUploadMetadata("video_id not-base64!")
+	assert.ErrorIs(t, err, service.Validation("invalid_upload_metadata", ""))
+}
+
+func TestTusRequestChecksVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(w)
+	context.Request = httptest.NewRequest("HEAD", "/uploads/1", nil)
+
+	assert.Equal(t, errTusVersion, tusRequest(context))
+	assert.Equal(t, TusResumable, w.Header().Get("Tus-Version"))
+
+	context.Request.Header.Set("Tus-Resumable", TusResumable)
+	assert.NoError(t, tusRequest(context))
+	assert.Equal(t, TusResumable, w.Header().Get("Tus-Resumable"))
+}
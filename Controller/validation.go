@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	entity "videoAPI/Entity"
+	service "videoAPI/Service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+)
+
+var (
+	validationOnce sync.Once
+	translator     *ut.UniversalTranslator
+)
+
+// setupValidation configures gin's validator to report JSON field names and
+// registers the message translations used in validation problems.
+func setupValidation() {
+	validationOnce.Do(func() {
+		english := en.New()
+		translator = ut.New(english, english, es.New(), fr.New())
+
+		validate, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+
+		validate.RegisterTagNameFunc(jsonFieldName)
+		validate.RegisterStructValidation(validateVideoLimits, entity.Video{})
+
+		registrations := map[string]func(*validator.Validate, ut.Translator) error{
+			"en": en_translations.RegisterDefaultTranslations,
+			"es": es_translations.RegisterDefaultTranslations,
+			"fr": fr_translations.RegisterDefaultTranslations,
+		}
+		for locale, register := range registrations {
+			trans, _ := translator.GetTranslator(locale)
+			if err := register(validate, trans); err != nil {
+				panic(err)
+			}
+		}
+	})
+}
+
+// validateVideoLimits checks the length limits in entity.Limits, reporting
+// failures under the same min/max rules a struct tag would.
+func validateVideoLimits(sl validator.StructLevel) {
+	video := sl.Current().Interface().(entity.Video)
+	limits := entity.Limits
+
+	checkLength(sl, video.Title, "title", "Title", limits.TitleMin, limits.TitleMax)
+	checkLength(sl, video.Description, "description", "Description", 0, limits.DescriptionMax)
+
+	if len(video.Tags) > limits.MaxTags {
+		sl.ReportError(video.Tags, "tags", "Tags", "max", strconv.Itoa(limits.MaxTags))
+	}
+	for i, tag := range video.Tags {
+		name := fmt.Sprintf("tags[%d]", i)
+		checkLength(sl, tag, name, name, 1, limits.TagMax)
+	}
+}
+
+func checkLength(sl validator.StructLevel, value, field, structField string, min, max int) {
+	length := utf8.RuneCountInString(value)
+	if length < min {
+		sl.ReportError(value, field, structField, "min", strconv.Itoa(min))
+	} else if max > 0 && length > max {
+		sl.ReportError(value, field, structField, "max", strconv.Itoa(max))
+	}
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// requestTranslator picks a translator from the Accept-Language header,
+// falling back to English.
+func requestTranslator(context *gin.Context) ut.Translator {
+	var locales []string
+	for _, part := range strings.Split(context.GetHeader("Accept-Language"), ",") {
+		tag := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		if tag == "" {
+			continue
+		}
+		tag = strings.ToLower(strings.ReplaceAll(tag, "-", "_"))
+		locales = append(locales, tag, strings.SplitN(tag, "_", 2)[0])
+	}
+
+	trans, _ := translator.FindTranslator(locales...)
+	return trans
+}
+
+// invalidBody turns a binding error into a validation problem, listing each
+// failing field when the validator or JSON decoder can tell which one it was.
+func invalidBody(context *gin.Context, err error) error {
+	var domainErr *service.Error
+	if errors.As(err, &domainErr) {
+		return err
+	}
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		trans := requestTranslator(context)
+
+		fields := make([]service.FieldError, 0, len(validationErrors))
+		for _, fieldErr := range validationErrors {
+			fields = append(fields, service.FieldError{
+				Field:   fieldPath(fieldErr.Namespace()),
+				Rule:    fieldErr.Tag(),
+				Message: fieldErr.Translate(trans),
+			})
+		}
+		return service.InvalidFields(fields)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return service.InvalidFields([]service.FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: typeErr.Field + " must be of type " + typeErr.Type.String(),
+		}})
+	}
+
+	return service.Validation("invalid_body", "Request body is not valid JSON")
+}
+
+// fieldPath drops the struct name from a validator namespace, so
+// "Video.title" becomes "title".
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	entity "videoAPI/Entity"
+	service "videoAPI/Service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func bindVideo(t *testing.T, body, language string) error {
+	setupValidation()
+
+	gin.SetMode(gin.TestMode)
+	context, _ := gin.CreateTestContext(httptest.NewRecorder())
+	context.Request = httptest.NewRequest("POST", "/videos", bytes.NewBufferString(body))
+	context.Request.Header.Set("Content-Type", "application/json")
+	context.Request.Header.Set("Accept-Language", language)
+
+	var video entity.Video
+	err := context.ShouldBindJSON(&video)
+	if err == nil {
+		return nil
+	}
+	return invalidBody(context, err)
+}
+
+func TestInvalidBodyListsFields(t *testing.T) {
+	err := bindVideo(t, `{"title":"x","url":"not a url"}`, "")
+
+	var domainErr *service.Error
+	assert.True(t, errors.As(err, &domainErr))
+	assert.Equal(t, "validation_failed", domainErr.Code)
+	assert.Equal(t, map[string]string{"title": "min", "url": "url"}, fieldRules(domainErr))
+	for _, field := range domainErr.Fields {
+		assert.Contains(t, field.Message, field.Field)
+	}
+}
+
+func TestInvalidBodyUsesConfiguredLimits(t *testing.T) {
+	defaults := entity.Limits
+	defer func() { entity.Limits = defaults }()
+
+	entity.Limits.TitleMax = 5
+	entity.Limits.MaxTags = 1
+	err := bindVideo(t, `{"title":"too long","url":"https://example.com","tags":["a","b"]}`, "")
+
+	assert.Equal(t, map[string]string{"title": "max", "tags": "max"}, fieldRules(err.(*service.Error)))
+}
+
+func fieldRules(err *service.Error) map[string]string {
+	rules := map[string]string{}
+	for _, field := range err.Fields {
+		rules[field.Field] = field.Rule
+	}
+	return rules
+}
+
+func TestInvalidBodyTranslates(t *testing.T) {
+	english := bindVideo(t, `{"title":"x","url":"https://example.com"}`, "en-US")
+	french := bindVideo(t, `{"title":"x","url":"https://example.com"}`, "fr-FR,fr;q=0.9")
+
+	assert.NotEqual(t, english.(*service.Error).Fields[0].Message, french.(*service.Error).Fields[0].Message)
+}
+
+func TestInvalidBodyReportsTypeErrors(t *testing.T) {
+	err := bindVideo(t, `{"title":5,"url":"https://example.com"}`, "")
+
+	fields := err.(*service.Error).Fields
+	assert.Len(t, fields, 1)
+	assert.Equal(t, "title", fields[0].Field)
+	assert.Equal(t, "type", fields[0].Rule)
+}
@@ -1,7 +1,11 @@
 package controller
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	entity "videoAPI/Entity"
@@ -10,6 +14,7 @@ import (
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -19,184 +24,500 @@ type VideoController interface {
 	Delete(context *gin.Context) error
 	FindByID(context *gin.Context) error
 	Update(context *gin.Context) error
+	Replace(context *gin.Context) error
+	FindTrash(context *gin.Context) error
+	Restore(context *gin.Context) error
+	FindRevisions(context *gin.Context) error
+	RevertToRevision(context *gin.Context) error
+	FindAuditEvents(context *gin.Context) error
 	HandleVideoSearchAndPaginate(context *gin.Context) error
+	Suggest(context *gin.Context) error
+	UploadMedia(context *gin.Context) error
+	Stream(context *gin.Context) error
+	PutRendition(context *gin.Context) error
+	MasterPlaylist(context *gin.Context) error
+	HLSFile(context *gin.Context) error
+	UploadPoster(context *gin.Context) error
+	Thumbnail(context *gin.Context) error
+	ListCaptions(context *gin.Context) error
+	GetCaptions(context *gin.Context) error
+	PutCaptions(context *gin.Context) error
+	DeleteCaptions(context *gin.Context) error
+	SearchCaptions(context *gin.Context) error
+	ListChapters(context *gin.Context) error
+	PutChapters(context *gin.Context) error
+	DeleteChapters(context *gin.Context) error
+	ListJobs(context *gin.Context) error
+	FindJob(context *gin.Context) error
+	RetryJob(context *gin.Context) error
+	UploadOptions(context *gin.Context) error
+	CreateUpload(context *gin.Context) error
+	FindUpload(context *gin.Context) error
+	WriteUpload(context *gin.Context) error
+	DeleteUpload(context *gin.Context) error
 
 	//Authorization
 	SignUp(context *gin.Context) error
 	LogIn(context *gin.Context) error
+	RefreshToken(context *gin.Context) error
+	SetUserRole(context *gin.Context) error
 }
 
 type controller struct {
 	service service.VideoService
+	audit   service.AuditLog
+	jobs    service.JobQueue
 }
 
 type SuccessResponse struct {
 	Message string `json:"message"`
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
-
 type SignUpResponse struct {
 	Message string `json:"message"`
 	Token   string `json:"token"`
 }
 
-func New(newService service.VideoService) VideoController {
+// RoleChange is the body of a request setting the role of a user.
+type RoleChange struct {
+	Role string `json:"role"`
+}
+
+func New(newService service.VideoService, audit service.AuditLog, jobs service.JobQueue) VideoController {
+	setupValidation()
 	return &controller{
 		service: newService,
+		audit:   audit,
+		jobs:    jobs,
 	}
 }
 
 // @Summary Get all videos
-// @Description Get all videos in DB
+// @Description Get all public videos in DB, with the caller's own and, for admins, all others
 // @ID find-all-videos
 // @Produce  json
 // @Success 200 {array} entity.Video
-// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} Problem
 // @Router /videos/all [get]
 func (c *controller) FindAll(context *gin.Context) error {
 	findVideos, err := c.service.FindAll()
-
 	if err != nil {
-		context.JSON(http.StatusNotFound, ErrorResponse{"Videos not found"})
 		return err
 	}
 
-	context.JSON(http.StatusOK, findVideos)
+	context.JSON(http.StatusOK, listable(context, findVideos))
 
 	return nil
 }
 
 // @Summary Save a video
-// @Description Save a video to the system
+// @Description Save a video to the system. The ID is generated by the server.
 // @ID save-video
 // @Accept  json
 // @Produce  json
 // @Param video body entity.Video true "Video to save"
-// @Success 200 {object} SuccessResponse
-// @Failure 400 {object} ErrorResponse
-// @Failure 409 {object} ErrorResponse
+// @Success 201 {object} entity.Video
+// @Header 201 {string} Location "URL of the created video"
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 409 {object} Problem
 // @Router /videos [post]
 func (c *controller) Save(context *gin.Context) error {
 	var video entity.Video
 
 	if err := context.ShouldBindJSON(&video); err != nil {
-		context.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
-		return err
+		return invalidBody(context, err)
 	}
+	video.Owner = CurrentUser(context)
 
-	if c.service.VideoExists(video.ID) {
-		context.JSON(http.StatusConflict, ErrorResponse{"Video ID Already Exists"})
-		return nil
+	saved, err := c.service.Save(video)
+	if err != nil {
+		return err
 	}
 
-	c.service.Save(video)
-	context.JSON(http.StatusOK, SuccessResponse{"Video saved"})
+	context.Header("Location", "/videos/"+saved.ID)
+	context.JSON(http.StatusCreated, saved)
 
 	return nil
 }
 
 // @Summary Delete a video by ID
-// @Description Delete a video by its ID
+// @Description Move a video to the trash. It can be restored until it is purged.
 // @ID delete-video
 // @Produce json
 // @Param id path string true "Video ID to delete"
+// @Param If-Match header string false "ETag the video must still have"
 // @Success 200 {object} SuccessResponse
-// @Failure 404 {object} ErrorResponse
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 412 {object} Problem
 // @Router /videos/{id} [delete]
 func (c *controller) Delete(context *gin.Context) error {
 	id := context.Param("id")
-
-	if !c.service.VideoExists(id) {
-		context.JSON(http.StatusNotFound, ErrorResponse{"Video not found"})
-		return nil
+	existingVideo, err := c.editableVideo(context, id)
+	if err != nil {
+		return err
 	}
 
-	err := c.service.Delete(id)
+	version, err := expectedVersion(context, existingVideo)
 	if err != nil {
 		return err
 	}
 
+	if err := c.service.Delete(id, version, CurrentUser(context)); err != nil {
+		return err
+	}
+	c.record(context, entity.AuditVideoDelete, CurrentUser(context), id, "")
+
 	context.JSON(http.StatusOK, SuccessResponse{"Video deleted"})
 
 	return nil
 }
 
+// @Summary List deleted videos
+// @Description List videos in the trash, most recently deleted first. Only public videos, the caller's own and, for admins, all others are listed.
+// @ID find-trash
+// @Produce json
+// @Success 200 {array} entity.Video
+// @Failure 500 {object} Problem
+// @Router /videos/trash [get]
+func (c *controller) FindTrash(context *gin.Context) error {
+	videos, err := c.service.FindTrash()
+	if err != nil {
+		return err
+	}
+
+	context.JSON(http.StatusOK, listable(context, videos))
+
+	return nil
+}
+
+// @Summary Restore a deleted video
+// @Description Take a video out of the trash
+// @ID restore-video
+// @Produce json
+// @Param id path string true "Video ID to restore"
+// @Success 200 {object} entity.Video
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Router /videos/{id}/restore [post]
+func (c *controller) Restore(context *gin.Context) error {
+	id := context.Param("id")
+	deleted, err := c.service.FindDeleted(id)
+	if err != nil {
+		return err
+	}
+	if err := mayEdit(context, deleted, service.ErrVideoNotInTrash); err != nil {
+		return err
+	}
+
+	video, err := c.service.Restore(id)
+	if err != nil {
+		return err
+	}
+	c.record(context, entity.AuditVideoRestore, CurrentUser(context), video.ID, "")
+
+	context.Header("ETag", etag(video))
+	context.JSON(http.StatusOK, video)
+
+	return nil
+}
+
 // @Summary Find a video by ID
-// @Description Find a video by its ID
+// @Description Find a video by its ID. Private videos are only found by their owner and admins. Chapters not set on the video are read from timestamp lines in its description.
 // @ID find-video
 // @Produce json
 // @Param id path string true "Video ID to find"
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {object} entity.Video
-// @Failure 404 {object} ErrorResponse
+// @Success 304
+// @Failure 404 {object} Problem
 // @Router /videos/{id} [get]
 func (c *controller) FindByID(context *gin.Context) error {
 	id := context.Param("id")
-	findVideo, _ := c.service.FindByID(id)
+	findVideo, err := c.viewableVideo(context)
+	if err != nil {
+		return err
+	}
 
-	if findVideo.ID == "" {
-		context.JSON(http.StatusNotFound, ErrorResponse{"Video not found"})
+	if err := c.service.RecordView(id); err != nil {
+		fmt.Printf("Error recording view of video %s: %v\n", id, err)
+	}
+
+	if notModified(context, findVideo) {
 		return nil
 	}
 
+	findVideo.Chapters = service.Chapters(findVideo)
+	context.Header("ETag", etag(findVideo))
 	context.JSON(http.StatusOK, findVideo)
 
 	return nil
 }
 
 // @Summary Update a video by ID
-// @Description Update a video by its ID
+// @Description Partially update a video with a JSON Merge Patch (RFC 7396, also accepted as application/json) or a JSON Patch (RFC 6902). Only client-editable fields may be changed.
 // @ID update-video
+// @Accept json
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
 // @Produce json
 // @Param id path string true "Video ID to update"
-// @Param updateFields body map[string]string true "Fields to update"
-// @Success 200 {object} SuccessResponse
-// @Failure 404 {object} ErrorResponse
-// @Failure 400 {object} ErrorResponse
-// @Router /videos/{id} [put]
+// @Param patch body object true "Merge patch or JSON Patch operations"
+// @Param If-Match header string false "ETag the video must still have"
+// @Success 200 {object} entity.Video
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 400 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 412 {object} Problem
+// @Failure 415 {object} Problem
+// @Router /videos/{id} [patch]
 func (c *controller) Update(context *gin.Context) error {
-	var updateFields map[string]string
-	if err := context.ShouldBindJSON(&updateFields); err != nil {
-		context.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
-		return nil
+	body, err := context.GetRawData()
+	if err != nil {
+		return service.Internal(err)
+	}
+
+	existingVideo, err := c.editableVideo(context, context.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	doc, err := editableDocument(existingVideo)
+	if err != nil {
+		return service.Internal(err)
+	}
+
+	var patched interface{}
+	switch context.ContentType() {
+	case MergePatchContentType, "application/json":
+		var patch interface{}
+		if err := json.Unmarshal(body, &patch); err != nil {
+			return invalidBody(context, err)
+		}
+		patched = applyMergePatch(doc, patch)
+	case JSONPatchContentType:
+		var operations []patchOperation
+		if err := json.Unmarshal(body, &operations); err != nil {
+			return invalidBody(context, err)
+		}
+		if patched, err = applyJSONPatch(doc, operations); err != nil {
+			return err
+		}
+	default:
+		return service.Unsupported("unsupported_patch_type", "Use "+MergePatchContentType+" or "+JSONPatchContentType)
+	}
+
+	video, err := patchedVideo(existingVideo, patched)
+	if err != nil {
+		return invalidBody(context, err)
+	}
+
+	return c.store(context, existingVideo, video)
+}
+
+// @Summary Replace a video by ID
+// @Description Replace all client-editable fields of a video
+// @ID replace-video
+// @Accept json
+// @Produce json
+// @Param id path string true "Video ID to replace"
+// @Param video body entity.Video true "New video"
+// @Param If-Match header string false "ETag the video must still have"
+// @Success 200 {object} entity.Video
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 400 {object} Problem
+// @Failure 412 {object} Problem
+// @Router /videos/{id} [put]
+func (c *controller) Replace(context *gin.Context) error {
+	var video entity.Video
+	if err := context.ShouldBindJSON(&video); err != nil {
+		return invalidBody(context, err)
+	}
+
+	existingVideo, err := c.editableVideo(context, context.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.store(context, existingVideo, withServerFields(video, existingVideo))
+}
+
+// store validates video, writes it over existingVideo and responds with the
+// stored result.
+func (c *controller) store(context *gin.Context, existingVideo, video entity.Video) error {
+	if err := binding.Validator.ValidateStruct(video); err != nil {
+		return invalidBody(context, err)
+	}
+
+	var err error
+	video.Version, err = expectedVersion(context, existingVideo)
+	if err != nil {
+		return err
+	}
+
+	if err := c.service.Update(&video, CurrentUser(context)); err != nil {
+		return err
+	}
+
+	context.Header("ETag", etag(video))
+	context.JSON(http.StatusOK, video)
+
+	return nil
+}
+
+// @Summary List revisions of a video
+// @Description List the recorded writes to a video, newest first. Private videos are only found by their owner and admins.
+// @ID find-revisions
+// @Produce json
+// @Param id path string true "Video ID"
+// @Success 200 {array} entity.Revision
+// @Failure 404 {object} Problem
+// @Router /videos/{id}/revisions [get]
+func (c *controller) FindRevisions(context *gin.Context) error {
+	video, err := c.viewableVideo(context)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := c.service.FindRevisions(video.ID)
+	if err != nil {
+		return err
+	}
+
+	context.JSON(http.StatusOK, revisions)
+
+	return nil
+}
+
+// @Summary Revert a video to a revision
+// @Description Restore the editable fields of a video as they were at a revision. The revert is recorded as a new revision.
+// @ID revert-revision
+// @Produce json
+// @Param id path string true "Video ID"
+// @Param rev path int true "Revision number"
+// @Param If-Match header string false "ETag the video must still have"
+// @Success 200 {object} entity.Video
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 412 {object} Problem
+// @Router /videos/{id}/revisions/{rev}/revert [post]
+func (c *controller) RevertToRevision(context *gin.Context) error {
+	number, err := strconv.ParseInt(context.Param("rev"), 10, 64)
+	if err != nil {
+		return service.Validation("invalid_revision", "Revision must be a number")
 	}
 
 	id := context.Param("id")
-	existingVideo, _ := c.service.FindByID(id)
+	existingVideo, err := c.editableVideo(context, id)
+	if err != nil {
+		return err
+	}
 
-	if existingVideo.ID == "" {
-		context.JSON(http.StatusNotFound, ErrorResponse{"Video not found"})
-		return nil
+	version, err := expectedVersion(context, existingVideo)
+	if err != nil {
+		return err
 	}
 
-	c.service.Update(&existingVideo, updateFields)
+	video, err := c.service.RevertToRevision(id, number, version, CurrentUser(context))
+	if err != nil {
+		return err
+	}
 
-	context.JSON(http.StatusOK, SuccessResponse{"Video updated"})
+	context.Header("ETag", etag(video))
+	context.JSON(http.StatusOK, video)
 
 	return nil
 }
 
 // @Summary Search and paginate videos
-// @Description Search and paginate videos
+// @Description Full-text search over title, description and tags, most relevant first. Words are matched after stemming, "quoted phrases" exactly and words ending in * as prefixes. Without a text query videos are listed oldest first and can also be paged with the opaque next_cursor.
 // @ID search-and-paginate
 // @Produce json
-// @Param page query string false "Page number"
 // @Param q query string false "Search query"
-// @Success 200 {array} entity.Video
-// @Failure 500 {object} ErrorResponse
+// @Param sort query string false "title, created_at or views; prefix with - or suffix with :desc for descending"
+// @Param tag query string false "Only videos with this tag"
+// @Param category query string false "Only videos in this category"
+// @Param owner query string false "Only videos of this owner"
+// @Param language query string false "Only videos in this language"
+// @Param visibility query string false "public, unlisted or private; videos that are not public are only listed for their owner and admins"
+// @Param created_after query string false "Created after (RFC 3339)"
+// @Param created_before query string false "Created before (RFC 3339)"
+// @Param duration_lt query number false "Shorter than, in seconds"
+// @Param duration_gt query number false "Longer than, in seconds"
+// @Param link_status query string false "ok or dead, as of the last check of the video URL"
+// @Param page query int false "Page number (max 10000)"
+// @Param per_page query int false "Videos per page (max 100)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param fuzzy query bool false "Match title and tag words despite typos"
+// @Param facets query bool false "Also count all matches by tag, category, language and duration"
+// @Success 200 {object} VideoPage
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
 // @Router /videos [get]
 func (c *controller) HandleVideoSearchAndPaginate(context *gin.Context) error {
-	page := context.DefaultQuery("page", "1")
-	q := context.Query("q")
+	request := service.SearchRequest{
+		Query:  context.Query("q"),
+		Sort:   context.Query("sort"),
+		Cursor: context.Query("cursor"),
+	}
 
-	videos, err := c.service.SearchAndPaginate(page, q, 10) // Adjust perPage as needed
+	var err error
+	if request.Filter, err = parseVideoFilter(context); err != nil {
+		return err
+	}
+	if request.Page, err = strconv.Atoi(context.DefaultQuery("page", "1")); err != nil {
+		return service.Validation("invalid_page", "Page must be a positive number")
+	}
+	if request.PerPage, err = strconv.Atoi(context.DefaultQuery("per_page", strconv.Itoa(service.DefaultPerPage))); err != nil {
+		return service.Validation("invalid_per_page", "per_page must be a number")
+	}
+	if request.Facets, err = strconv.ParseBool(context.DefaultQuery("facets", "false")); err != nil {
+		return service.Validation("invalid_facets", "facets must be true or false")
+	}
+	if request.Fuzzy, err = strconv.ParseBool(context.DefaultQuery("fuzzy", "false")); err != nil {
+		return service.Validation("invalid_fuzzy", "fuzzy must be true or false")
+	}
+
+	page, err := c.service.SearchAndPaginate(request)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, ErrorResponse{err.Error()})
 		return err
 	}
-	context.JSON(http.StatusOK, videos)
+
+	context.JSON(http.StatusOK, newVideoPage(context, page))
+	return nil
+}
+
+// @Summary Suggest video titles
+// @Description Completes a partly typed title to the most viewed public videos with a word starting with prefix.
+// @ID suggest-videos
+// @Produce json
+// @Param prefix query string true "Typed text"
+// @Param limit query int false "Suggestions to return (max 25)"
+// @Success 200 {array} entity.Suggestion
+// @Failure 400 {object} Problem
+// @Router /videos/suggest [get]
+func (c *controller) Suggest(context *gin.Context) error {
+	limit, err := strconv.Atoi(context.DefaultQuery("limit", strconv.Itoa(service.DefaultSuggestions)))
+	if err != nil {
+		return service.Validation("invalid_limit", "limit must be a number")
+	}
+
+	suggestions, err := c.service.Suggest(context.Query("prefix"), limit)
+	if err != nil {
+		return err
+	}
+
+	context.JSON(http.StatusOK, suggestions)
 	return nil
 }
 
@@ -206,22 +527,22 @@ func (c *controller) HandleVideoSearchAndPaginate(context *gin.Context) error {
 // @Produce json
 // @Param body body object true "User data"
 // @Success 200 {object} SuccessResponse
-// @Failure 400 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
+// @Failure 400 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 500 {object} Problem
 // @Router /signup [post]
 func (c *controller) SignUp(context *gin.Context) error {
 	var user entity.User
 
 	if err := context.ShouldBindJSON(&user); err != nil {
-		context.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
-		return err
+		return invalidBody(context, err)
 	}
 
 	err := c.service.CreateUser(user)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, ErrorResponse{"Failed to create user"})
 		return err
 	}
+	c.record(context, entity.AuditSignup, user.Email, user.Email, "")
 
 	context.JSON(http.StatusOK, SuccessResponse{"User created successfully"})
 
@@ -234,46 +555,127 @@ func (c *controller) SignUp(context *gin.Context) error {
 // @Produce json
 // @Param body body object true "User data"
 // @Success 200 {object} SignUpResponse
-// @Failure 400 {object} ErrorResponse
-// @Failure 401 {object} ErrorResponse
-// @Failure 401 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 500 {object} Problem
 // @Router /login [post]
 func (c *controller) LogIn(context *gin.Context) error {
 	var login_user entity.User
 
 	if err := context.ShouldBindJSON(&login_user); err != nil {
-		context.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
-		return err
+		return invalidBody(context, err)
 	}
 
 	user, err := c.service.GetUserByEmail(login_user.Email)
 	if err != nil {
-		context.JSON(http.StatusUnauthorized, ErrorResponse{"Invalid email"})
+		if errors.Is(err, service.ErrUserNotFound) {
+			c.record(context, entity.AuditLoginFailure, login_user.Email, login_user.Email, "unknown email")
+			return service.ErrInvalidCredentials
+		}
 		return err
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(login_user.Password))
 	if err != nil {
-		context.JSON(http.StatusUnauthorized, ErrorResponse{"Invalid password"})
+		c.record(context, entity.AuditLoginFailure, user.Email, user.Email, "wrong password")
+		return service.ErrInvalidCredentials
+	}
+
+	token, err := generateJWTToken(user.Email, user.Role)
+	if err != nil {
+		return service.Internal(err)
+	}
+	c.record(context, entity.AuditLoginSuccess, user.Email, user.Email, "")
+
+	context.JSON(http.StatusOK, SignUpResponse{"Login successful", token})
+
+	return nil
+}
+
+// @Summary Refresh a token
+// @Description Issue a new token for the authenticated user, carrying their current role
+// @ID refresh-token
+// @Produce json
+// @Success 200 {object} SignUpResponse
+// @Failure 401 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /token/refresh [post]
+func (c *controller) RefreshToken(context *gin.Context) error {
+	user, err := c.service.GetUserByEmail(CurrentUser(context))
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return service.ErrInvalidCredentials
+		}
 		return err
 	}
 
-	token, err := generateJWTToken(user.Email)
+	token, err := generateJWTToken(user.Email, user.Role)
+	if err != nil {
+		return service.Internal(err)
+	}
+	c.record(context, entity.AuditTokenRefresh, user.Email, user.Email, "")
+
+	context.JSON(http.StatusOK, SignUpResponse{"Token refreshed", token})
+
+	return nil
+}
+
+// @Summary Set the role of a user
+// @Description Make a user an admin, or a regular user with an empty role. Admin only.
+// @ID set-user-role
+// @Produce json
+// @Param email path string true "User email"
+// @Param body body RoleChange true "New role"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /admin/users/{email}/role [put]
+func (c *controller) SetUserRole(context *gin.Context) error {
+	var change RoleChange
+	if err := context.ShouldBindJSON(&change); err != nil {
+		return invalidBody(context, err)
+	}
+
+	email := context.Param("email")
+	previous, err := c.service.SetUserRole(email, change.Role)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, ErrorResponse{"Failed to generate JWT token"})
 		return err
 	}
+	c.record(context, entity.AuditRoleChange, CurrentUser(context), email, fmt.Sprintf("%q -> %q", previous.Role, change.Role))
 
-	context.JSON(http.StatusOK, SignUpResponse{"Login successful", token})
+	context.JSON(http.StatusOK, SuccessResponse{"Role updated"})
 
 	return nil
 }
 
-func generateJWTToken(email string) (string, error) {
+// CurrentUser returns the email of the user authenticated by the auth
+// middlewares, or "" for anonymous requests.
+func CurrentUser(context *gin.Context) string {
+	claims, ok := context.Get("user")
+	if !ok {
+		return ""
+	}
+	mapClaims, _ := claims.(jwt.MapClaims)
+	email, _ := mapClaims["email"].(string)
+	return email
+}
+
+// CurrentRole returns the role claimed by the token of the request, or "".
+func CurrentRole(context *gin.Context) string {
+	claims, _ := context.Get("user")
+	mapClaims, _ := claims.(jwt.MapClaims)
+	role, _ := mapClaims["role"].(string)
+	return role
+}
+
+func generateJWTToken(email, role string) (string, error) {
 	const secretKey = "vcsbackend"
 	claims := jwt.MapClaims{
 		"email": email,
+		"role":  role,
 		"exp":   time.Now().Add(time.Hour * 24).Unix(), // Token expires in 24 hours
 	}
 
@@ -0,0 +1,27 @@
+package entity
+
+import "time"
+
+// Audit actions.
+const (
+	AuditSignup       = "user.signup"
+	AuditLoginSuccess = "auth.login.success"
+	AuditLoginFailure = "auth.login.failure"
+	AuditTokenRefresh = "auth.token.refresh"
+	AuditRoleChange   = "user.role.change"
+	AuditVideoDelete  = "video.delete"
+	AuditVideoRestore = "video.restore"
+	AuditJobRetry     = "job.retry"
+)
+
+// AuditEvent is one entry of the append-only audit log.
+type AuditEvent struct {
+	Time      time.Time `json:"time" bson:"time"`
+	Action    string    `json:"action" bson:"action"`
+	Actor     string    `json:"actor" bson:"actor"`
+	Target    string    `json:"target,omitempty" bson:"target,omitempty"`
+	IP        string    `json:"ip" bson:"ip"`
+	UserAgent string    `json:"user_agent" bson:"user_agent"`
+	RequestID string    `json:"request_id" bson:"request_id"`
+	Detail    string    `json:"detail,omitempty" bson:"detail,omitempty"`
+}
@@ -0,0 +1,27 @@
+package entity
+
+import "time"
+
+// CaptionTrack is the captions of a video in one language, stored as WebVTT
+// under Key in the blob store.
+type CaptionTrack struct {
+	Language  string    `json:"language" bson:"language" binding:"required,bcp47_language_tag"`
+	Label     string    `json:"label,omitempty" bson:"label,omitempty" binding:"max=100"`
+	Cues      int       `json:"cues" bson:"cues"`
+	Key       string    `json:"-" bson:"key"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// Cue is one caption, shown from Start to End seconds into the video.
+type Cue struct {
+	Start float64 `json:"start" bson:"start"`
+	End   float64 `json:"end" bson:"end"`
+	Text  string  `json:"text" bson:"text"`
+}
+
+// CaptionHit is a cue matching a caption search, with the video it is in.
+type CaptionHit struct {
+	Video    Video  `json:"video"`
+	Language string `json:"language"`
+	Cue
+}
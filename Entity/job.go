@@ -0,0 +1,31 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Job states. A job is ready to run, scheduled to become ready at RunAt
+// (when delayed or backing off after a failure), running, or dead once it
+// has failed MaxAttempts times. Jobs that succeed are removed.
+const (
+	JobReady     = "ready"
+	JobScheduled = "scheduled"
+	JobRunning   = "running"
+	JobDead      = "dead"
+)
+
+// Job is a unit of background work, run by the handler registered for its
+// Type. Payload is handed to the handler as is.
+type Job struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload,omitempty" swaggertype:"object"`
+	State       string          `json:"state"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	RunAt       time.Time       `json:"run_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
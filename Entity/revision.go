@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// Revision records one write to a video. Number is the video version the
+// write produced; Video is a snapshot of the video after the write.
+type Revision struct {
+	VideoID   string                 `json:"video_id" bson:"video_id"`
+	Number    int64                  `json:"number" bson:"number"`
+	Actor     string                 `json:"actor" bson:"actor"`
+	Timestamp time.Time              `json:"timestamp" bson:"timestamp"`
+	Changes   map[string]FieldChange `json:"changes" bson:"changes"`
+	Video     Video                  `json:"video" bson:"video"`
+}
+
+// FieldChange is the before and after value of one changed field.
+type FieldChange struct {
+	From interface{} `json:"from" bson:"from"`
+	To   interface{} `json:"to" bson:"to"`
+}
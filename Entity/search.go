@@ -0,0 +1,45 @@
+package entity
+
+// SearchResult is a video matched by a search. Score is the text relevance
+// and Highlights holds snippets of the matching fields, keyed by JSON name,
+// with matches wrapped in <mark> tags.
+type SearchResult struct {
+	Video      `bson:",inline"`
+	Score      float64           `json:"score,omitempty" bson:"score,omitempty"`
+	Highlights map[string]string `json:"highlights,omitempty" bson:"-"`
+}
+
+// SearchPage is one page of search results. NextCursor, when set, fetches
+// the following page by key instead of by page number. Facets covers all
+// matches, not just this page, and is only set when requested.
+type SearchPage struct {
+	Items      []SearchResult `json:"items"`
+	Total      int64          `json:"total"`
+	Page       int            `json:"page,omitempty"`
+	PerPage    int            `json:"per_page"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Facets     *Facets        `json:"facets,omitempty"`
+	HasMore    bool           `json:"-"`
+}
+
+// FacetCount is the number of matching videos sharing one value.
+type FacetCount struct {
+	Value string `json:"value" bson:"_id"`
+	Count int64  `json:"count" bson:"count"`
+}
+
+// Facets counts the videos matching a search by tag, category, language and
+// duration bucket, most common first.
+type Facets struct {
+	Tags       []FacetCount `json:"tags" bson:"tags"`
+	Categories []FacetCount `json:"categories" bson:"categories"`
+	Languages  []FacetCount `json:"languages" bson:"languages"`
+	Durations  []FacetCount `json:"durations" bson:"durations"`
+}
+
+// Suggestion is a title completion for search-as-you-type.
+type Suggestion struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Views int64  `json:"views"`
+}
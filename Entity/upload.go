@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+// Upload is a resumable upload of a video file. Offset counts the bytes
+// received so far, kept in Parts in the order they arrived; once it reaches
+// Length the file is attached to the video and CompletedAt is set.
+type Upload struct {
+	ID          string            `json:"id" bson:"id"`
+	VideoID     string            `json:"video_id" bson:"video_id"`
+	Owner       string            `json:"owner" bson:"owner"`
+	Length      int64             `json:"length" bson:"length"`
+	Offset      int64             `json:"offset" bson:"offset"`
+	Metadata    map[string]string `json:"metadata,omitempty" bson:"metadata,omitempty"`
+	Parts       []UploadPart      `json:"-" bson:"parts"`
+	CreatedAt   time.Time         `json:"created_at" bson:"created_at"`
+	ExpiresAt   time.Time         `json:"expires_at" bson:"expires_at"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+}
+
+// UploadPart is one stored chunk of an upload, starting at Offset.
+type UploadPart struct {
+	Key    string `bson:"key"`
+	Offset int64  `bson:"offset"`
+	Size   int64  `bson:"size"`
+}
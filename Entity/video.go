@@ -1,10 +1,145 @@
 package entity
 
+import "time"
+
+// Visibility values for Video.Visibility.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// Video is a catalog entry. Title, description and tag lengths are checked
+// against Limits rather than struct tags so they can be configured. ID, Owner,
+// the timestamps, Version, Views, Media, MediaInfo, Renditions, Poster,
+// Captions and LinkStatus are set by the server, and Chapters through their
+// own endpoints; Version is bumped on every write and backs the ETag.
 type Video struct {
-	ID          string `bson:"id" gorm:"primaryKey"`
-	Title       string `json:"title" binding:"min=2,max=10"`
-	Description string `json:"description" binding:"max=20"`
-	URL         string `json:"url" binding:"required,url"`
+	ID            string         `json:"id" bson:"id" gorm:"primaryKey"`
+	Title         string         `json:"title"`
+	Description   string         `json:"description"`
+	URL           string         `json:"url" binding:"required,url"`
+	Tags          []string       `json:"tags" bson:"tags"`
+	Category      string         `json:"category" bson:"category" binding:"max=50"`
+	Duration      float64        `json:"duration" bson:"duration" binding:"gte=0"` // seconds
+	Language      string         `json:"language" bson:"language" binding:"omitempty,bcp47_language_tag"`
+	ThumbnailURL  string         `json:"thumbnail_url" bson:"thumbnail_url" binding:"omitempty,url"`
+	Visibility    string         `json:"visibility" bson:"visibility" binding:"omitempty,oneof=public unlisted private"`
+	Owner         string         `json:"owner" bson:"owner"`
+	CreatedAt     time.Time      `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at" bson:"updated_at"`
+	Version       int64          `json:"version" bson:"version"`
+	Views         int64          `json:"views" bson:"views"`
+	DeletedAt     *time.Time     `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	DeletedBy     string         `json:"deleted_by,omitempty" bson:"deleted_by,omitempty"`
+	Media         *Media         `json:"media,omitempty" bson:"media,omitempty"`
+	Renditions    []Rendition    `json:"renditions,omitempty" bson:"renditions,omitempty"`
+	Poster        *Poster        `json:"poster,omitempty" bson:"poster,omitempty"`
+	Captions      []CaptionTrack `json:"captions,omitempty" bson:"captions,omitempty"`
+	Chapters      []Chapter      `json:"chapters,omitempty" bson:"chapters,omitempty"`
+	LinkStatus    *LinkStatus    `json:"link_status,omitempty" bson:"link_status,omitempty"`
+	SchemaVersion int            `json:"-" bson:"schema_version"`
+	MediaInfo     `bson:",inline"`
+}
+
+// Media describes a video file uploaded to our own storage. Key locates it
+// in the blob store; URL of the video then points at it.
+type Media struct {
+	Key         string    `json:"-" bson:"key"`
+	ContentType string    `json:"content_type" bson:"content_type"`
+	Size        int64     `json:"size" bson:"size"`
+	UploadedAt  time.Time `json:"uploaded_at" bson:"uploaded_at"`
+}
+
+// MediaInfo is read from the box structure of an uploaded MP4 file. Its
+// fields appear directly on the Video.
+type MediaInfo struct {
+	Width      int        `json:"width,omitempty" bson:"width,omitempty"`
+	Height     int        `json:"height,omitempty" bson:"height,omitempty"`
+	VideoCodec string     `json:"video_codec,omitempty" bson:"video_codec,omitempty"`
+	AudioCodec string     `json:"audio_codec,omitempty" bson:"audio_codec,omitempty"`
+	RecordedAt *time.Time `json:"recorded_at,omitempty" bson:"recorded_at,omitempty"`
+}
+
+// Poster is an uploaded poster image, stored as a JPEG thumbnail of each of
+// Sizes (widths in pixels) under Key in the blob store.
+type Poster struct {
+	Key        string    `json:"-" bson:"key"`
+	Sizes      []int     `json:"sizes" bson:"sizes"`
+	UploadedAt time.Time `json:"uploaded_at" bson:"uploaded_at"`
+}
+
+// Rendition is one HLS variant of a video, packaged elsewhere into segments
+// stored under videos/<id>/hls/<name>/ in the blob store.
+type Rendition struct {
+	Name      string    `json:"name" bson:"name" binding:"required,max=32,alphanum"`
+	Bandwidth int       `json:"bandwidth" bson:"bandwidth" binding:"required,gt=0"` // bits per second
+	Width     int       `json:"width,omitempty" bson:"width,omitempty" binding:"gte=0"`
+	Height    int       `json:"height,omitempty" bson:"height,omitempty" binding:"gte=0"`
+	Codecs    string    `json:"codecs,omitempty" bson:"codecs,omitempty" binding:"omitempty,max=100,printascii,excludes=\""`
+	Segments  []Segment `json:"segments" bson:"segments" binding:"required,min=1,dive"`
+}
+
+// Segment is one file of a rendition, played for Duration seconds.
+type Segment struct {
+	File     string  `json:"file" bson:"file" binding:"required"`
+	Duration float64 `json:"duration" bson:"duration" binding:"gt=0"`
+}
+
+// Link states for LinkStatus.State.
+const (
+	LinkOK   = "ok"
+	LinkDead = "dead"
+)
+
+// LinkStatus is the outcome of the last check of the URL of a video. URL is
+// the one checked, which the video may have moved on from since.
+type LinkStatus struct {
+	State      string    `json:"state" bson:"state"`
+	URL        string    `json:"url" bson:"url"`
+	StatusCode int       `json:"status_code,omitempty" bson:"status_code,omitempty"`
+	FinalURL   string    `json:"final_url,omitempty" bson:"final_url,omitempty"` // after redirects
+	Error      string    `json:"error,omitempty" bson:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at" bson:"checked_at"`
+}
+
+// Chapter marks where a part of a video starts, Start seconds in.
+type Chapter struct {
+	Start float64 `json:"start" bson:"start" binding:"gte=0"`
+	Title string  `json:"title" bson:"title" binding:"required,max=100"`
+}
+
+// EditableVideoFields are the JSON names of the Video fields a client may
+// change. Everything else is owned by the server, as is duration once a file
+// has been uploaded.
+var EditableVideoFields = []string{
+	"title",
+	"description",
+	"url",
+	"tags",
+	"category",
+	"duration",
+	"language",
+	"thumbnail_url",
+	"visibility",
+}
+
+// VideoLimits bounds the free-text fields of a Video.
+type VideoLimits struct {
+	TitleMin       int
+	TitleMax       int
+	DescriptionMax int
+	MaxTags        int
+	TagMax         int
+}
+
+// Limits is applied when a Video is validated. It is set once at startup.
+var Limits = VideoLimits{
+	TitleMin:       2,
+	TitleMax:       100,
+	DescriptionMax: 5000,
+	MaxTags:        20,
+	TagMax:         30,
 }
 
 type User struct {
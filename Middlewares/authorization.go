@@ -1,9 +1,11 @@
 package middlewares
 
 import (
-	"net/http"
+	"errors"
 	"strings"
 
+	service "videoAPI/Service"
+
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 )
@@ -30,46 +32,79 @@ import (
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(context *gin.Context) {
-		authHeader := context.GetHeader("Authorization")
-		if authHeader == "" {
-			context.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		if context.GetHeader("Authorization") == "" {
+			context.Error(service.Unauthorized("missing_token", "Unauthorized"))
 			context.Abort()
 			return
 		}
 
-		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			context.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token format"})
-			context.Abort()
+		authenticate(context)
+	}
+}
+
+// OptionalAuth attaches the user of a valid bearer token to the context but
+// lets anonymous requests through. A token that is present but invalid is
+// still rejected.
+func OptionalAuth() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if context.GetHeader("Authorization") == "" {
+			context.Next()
 			return
 		}
 
-		tokenString := tokenParts[1]
-
-		// Parse and validate the JWT token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			const secretKey = "vcsbackend"
-			return []byte(secretKey), nil
-		})
+		authenticate(context)
+	}
+}
 
-		if err != nil || !token.Valid {
-			context.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+// RequireRole rejects requests whose token does not carry role. It must run
+// after AuthMiddleware.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		claims, _ := context.Get("user")
+		mapClaims, _ := claims.(jwt.MapClaims)
+		if userRole, _ := mapClaims["role"].(string); userRole != role {
+			context.Error(service.Forbidden("forbidden", "Insufficient role"))
 			context.Abort()
 			return
 		}
 
-		// Extract user information from the token
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse token claims"})
-			context.Abort()
-			return
-		}
+		context.Next()
+	}
+}
 
-		// Attach user information to the context for later use
-		context.Set("user", claims)
+func authenticate(context *gin.Context) {
+	tokenParts := strings.Split(context.GetHeader("Authorization"), " ")
+	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+		context.Error(service.Unauthorized("invalid_token_format", "Invalid token format"))
+		context.Abort()
+		return
+	}
 
-		// Continue processing the request
-		context.Next()
+	tokenString := tokenParts[1]
+
+	// Parse and validate the JWT token
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		const secretKey = "vcsbackend"
+		return []byte(secretKey), nil
+	})
+
+	if err != nil || !token.Valid {
+		context.Error(service.Unauthorized("invalid_token", "Invalid token"))
+		context.Abort()
+		return
+	}
+
+	// Extract user information from the token
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		context.Error(service.Internal(errors.New("failed to parse token claims")))
+		context.Abort()
+		return
 	}
+
+	// Attach user information to the context for later use
+	context.Set("user", claims)
+
+	// Continue processing the request
+	context.Next()
 }
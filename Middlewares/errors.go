@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	controller "videoAPI/Controller"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorHandler renders the last error attached to the context as a problem
+// response, unless a handler has already written a body.
+func ErrorHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		context.Next()
+
+		if len(context.Errors) == 0 || context.Writer.Written() {
+			return
+		}
+
+		controller.WriteProblem(context, context.Errors.Last().Err)
+	}
+}
@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	controller "videoAPI/Controller"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestID tags each request with an ID, reusing a well-formed X-Request-ID
+// from the client and echoing it on the response.
+func RequestID() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		id := context.GetHeader("X-Request-ID")
+		if _, err := uuid.Parse(id); err != nil {
+			id = uuid.NewString()
+		}
+
+		context.Set(controller.RequestIDKey, id)
+		context.Header("X-Request-ID", id)
+
+		context.Next()
+	}
+}
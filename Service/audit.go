@@ -0,0 +1,227 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	entity "videoAPI/Entity"
+)
+
+// AuditFilter selects audit events. Empty fields match everything.
+type AuditFilter struct {
+	Action string
+	Actor  string
+	Target string
+	From   time.Time
+	To     time.Time
+}
+
+func (filter AuditFilter) matches(event entity.AuditEvent) bool {
+	return (filter.Action == "" || event.Action == filter.Action) &&
+		(filter.Actor == "" || event.Actor == filter.Actor) &&
+		(filter.Target == "" || event.Target == filter.Target) &&
+		(filter.From.IsZero() || !event.Time.Before(filter.From)) &&
+		(filter.To.IsZero() || event.Time.Before(filter.To))
+}
+
+// AuditSink is a destination for audit events. Sinks only ever append.
+type AuditSink interface {
+	Write(event entity.AuditEvent) error
+}
+
+// AuditQuerier is implemented by sinks that can be searched. Events are
+// returned newest first together with the total number of matches.
+type AuditQuerier interface {
+	Query(filter AuditFilter, skip, limit int) ([]entity.AuditEvent, int64, error)
+}
+
+// AuditLog records security-relevant events to every configured sink and
+// answers queries from the first sink that supports them.
+type AuditLog interface {
+	Record(event entity.AuditEvent)
+	Query(filter AuditFilter, skip, limit int) ([]entity.AuditEvent, int64, error)
+}
+
+type auditLog struct {
+	sinks []AuditSink
+}
+
+func NewAuditLog(sinks ...AuditSink) AuditLog {
+	return &auditLog{sinks: sinks}
+}
+
+// Record never fails the request being audited; sink errors are logged.
+func (log *auditLog) Record(event entity.AuditEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	for _, sink := range log.sinks {
+		if err := sink.Write(event); err != nil {
+			fmt.Printf("Error writing audit event %s: %v\n", event.Action, err)
+		}
+	}
+}
+
+func (log *auditLog) Query(filter AuditFilter, skip, limit int) ([]entity.AuditEvent, int64, error) {
+	for _, sink := range log.sinks {
+		if querier, ok := sink.(AuditQuerier); ok {
+			events, total, err := querier.Query(filter, skip, limit)
+			if err != nil {
+				return nil, 0, Internal(err)
+			}
+			return events, total, nil
+		}
+	}
+	return nil, 0, Internal(fmt.Errorf("no queryable audit sink configured"))
+}
+
+type mongoAuditSink struct {
+	collection *mongo.Collection
+}
+
+func NewMongoAuditSink(collection *mongo.Collection) AuditSink {
+	return &mongoAuditSink{collection: collection}
+}
+
+func (sink *mongoAuditSink) Write(event entity.AuditEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := sink.collection.InsertOne(ctx, event)
+	return err
+}
+
+func (sink *mongoAuditSink) Query(filter AuditFilter, skip, limit int) ([]entity.AuditEvent, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := bson.M{}
+	for field, value := range map[string]string{"action": filter.Action, "actor": filter.Actor, "target": filter.Target} {
+		if value != "" {
+			query[field] = value
+		}
+	}
+	timeRange := bson.M{}
+	if !filter.From.IsZero() {
+		timeRange["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		timeRange["$lt"] = filter.To
+	}
+	if len(timeRange) > 0 {
+		query["time"] = timeRange
+	}
+
+	total, err := sink.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "time", Value: -1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit))
+	cursor, err := sink.collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []entity.AuditEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+func (sink *mongoAuditSink) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := sink.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "time", Value: -1}}},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "time", Value: -1}}},
+		{Keys: bson.D{{Key: "actor", Value: 1}, {Key: "time", Value: -1}}},
+	})
+	return err
+}
+
+// fileAuditSink appends events as JSON lines to a file opened in append
+// mode, so earlier entries are never rewritten.
+type fileAuditSink struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+func NewFileAuditSink(path string) (AuditSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &fileAuditSink{path: path, file: file}, nil
+}
+
+func (sink *fileAuditSink) Write(event entity.AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	_, err = sink.file.Write(append(line, '\n'))
+	return err
+}
+
+// Query scans the whole file; it is meant for small deployments where the
+// file is the only sink.
+func (sink *fileAuditSink) Query(filter AuditFilter, skip, limit int) ([]entity.AuditEvent, int64, error) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	file, err := os.Open(sink.path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	var matches []entity.AuditEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event entity.AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if filter.matches(event) {
+			matches = append(matches, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Time.After(matches[j].Time) })
+
+	total := int64(len(matches))
+	events := []entity.AuditEvent{}
+	if skip < len(matches) {
+		end := skip + limit
+		if end > len(matches) {
+			end = len(matches)
+		}
+		events = append(events, matches[skip:end]...)
+	}
+	return events, total, nil
+}
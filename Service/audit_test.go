@@ -0,0 +1,38 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	entity "videoAPI/Entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileAuditSinkQuery(t *testing.T) {
+	sink, err := NewFileAuditSink(filepath.Join(t.TempDir(), "audit.log"))
+	assert.NoError(t, err)
+	log := NewAuditLog(sink)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	log.Record(entity.AuditEvent{Time: start, Action: entity.AuditLoginFailure, Actor: "a@b.c"})
+	log.Record(entity.AuditEvent{Time: start.Add(time.Minute), Action: entity.AuditLoginSuccess, Actor: "a@b.c"})
+	log.Record(entity.AuditEvent{Time: start.Add(2 * time.Minute), Action: entity.AuditLoginFailure, Actor: "x@y.z"})
+	log.Record(entity.AuditEvent{Time: start.Add(3 * time.Minute), Action: entity.AuditVideoDelete, Actor: "a@b.c", Target: "1"})
+
+	events, total, err := log.Query(AuditFilter{Action: entity.AuditLoginFailure}, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, "x@y.z", events[0].Actor)
+
+	events, total, err = log.Query(AuditFilter{Actor: "a@b.c", From: start.Add(time.Minute)}, 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, []string{entity.AuditLoginSuccess}, []string{events[0].Action})
+}
+
+func TestAuditLogWithoutQuerier(t *testing.T) {
+	_, _, err := NewAuditLog().Query(AuditFilter{}, 0, 10)
+	assert.Equal(t, KindInternal, AsError(err).Kind)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// BlobStore keeps uploaded files. Keys are slash-separated paths such as
+// "videos/<id>/media/<upload>".
+type BlobStore interface {
+	// Put stores body under key, replacing any blob already there. size is
+	// the length of body, or -1 if it is not known in advance.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) (BlobInfo, error)
+	// Get opens the blob stored under key. The caller closes it. Seeking is
+	// cheap, so ranges can be served without reading what comes before.
+	Get(ctx context.Context, key string) (io.ReadSeekCloser, BlobInfo, error)
+	// Delete removes the blob under key. Deleting a missing blob is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+}
+
+// BlobInfo describes a stored blob. ContentType is empty when the store does
+// not keep it.
+type BlobInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+	ETag        string
+}
+
+var ErrBlobNotFound = NotFound("blob_not_found", "Stored file not found")
+
+type localBlobStore struct {
+	root string
+}
+
+// NewLocalBlobStore keeps blobs as files under root, creating it if needed.
+func NewLocalBlobStore(root string) (BlobStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &localBlobStore{root: root}, nil
+}
+
+// path maps key to a file under root. Cleaning the key as an absolute path
+// first keeps ".." from escaping root.
+func (store *localBlobStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(store.root, filepath.FromSlash(clean[1:])), nil
+}
+
+// Put writes to a temporary file next to the target and renames it into
+// place, so readers never see a partly written blob.
+func (store *localBlobStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) (BlobInfo, error) {
+	name, err := store.path(key)
+	if err != nil {
+		return BlobInfo{}, Internal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return BlobInfo{}, Internal(err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return BlobInfo{}, Internal(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return BlobInfo{}, Internal(err)
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return BlobInfo{}, Internal(err)
+	}
+	blob := localBlobInfo(key, info)
+	blob.Size = written
+	blob.ContentType = contentType
+	return blob, nil
+}
+
+func (store *localBlobStore) Get(ctx context.Context, key string) (io.ReadSeekCloser, BlobInfo, error) {
+	name, err := store.path(key)
+	if err != nil {
+		return nil, BlobInfo{}, ErrBlobNotFound
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, BlobInfo{}, ErrBlobNotFound
+		}
+		return nil, BlobInfo{}, Internal(err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, BlobInfo{}, Internal(err)
+	}
+	return file, localBlobInfo(key, info), nil
+}
+
+func (store *localBlobStore) Delete(ctx context.Context, key string) error {
+	name, err := store.path(key)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return Internal(err)
+	}
+	return nil
+}
+
+func localBlobInfo(key string, info os.FileInfo) BlobInfo {
+	return BlobInfo{
+		Key:     key,
+		Size:    info.Size(),
+		ModTime: info.ModTime().UTC(),
+		ETag:    fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()),
+	}
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config locates a bucket on S3 or on an S3-compatible server such as
+// MinIO. Buckets are addressed by path, as in <Endpoint>/<Bucket>/<key>.
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	Client          *http.Client
+}
+
+type s3BlobStore struct {
+	config S3Config
+}
+
+// NewS3BlobStore keeps blobs as objects in an S3 bucket. Requests are signed
+// with AWS Signature Version 4; payloads are sent unsigned so they can be
+// streamed.
+func NewS3BlobStore(config S3Config) BlobStore {
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 30 * time.Minute}
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	config.Endpoint = strings.TrimRight(config.Endpoint, "/")
+	return &s3BlobStore{config: config}
+}
+
+func (store *s3BlobStore) objectURL(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return store.config.Endpoint + "/" + s3Escape(store.config.Bucket) + "/" + strings.Join(segments, "/")
+}
+
+// s3Escape percent-encodes everything but the unreserved characters, as the
+// canonical request of Signature Version 4 requires.
+func s3Escape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func (store *s3BlobStore) do(ctx context.Context, method, key string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, store.objectURL(key), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.ContentLength = size
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	signS3Request(request, store.config, time.Now().UTC())
+	return store.config.Client.Do(request)
+}
+
+// signS3Request adds the Signature Version 4 headers to request.
+func signS3Request(request *http.Request, config S3Config, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		"host:" + request.URL.Host,
+		"x-amz-content-sha256:UNSIGNED-PAYLOAD",
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	scope := date + "/" + config.Region + "/s3/aws4_request"
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashedRequest[:])
+
+	key := []byte("AWS4" + config.SecretAccessKey)
+	for _, part := range []string{date, config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+config.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func s3Error(method, key string, response *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	return Internal(fmt.Errorf("s3 %s %s: %s: %s", method, key, response.Status, strings.TrimSpace(string(message))))
+}
+
+// Put spools bodies of unknown size to a temporary file first, because S3
+// needs the length of an object before it is sent.
+func (store *s3BlobStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) (BlobInfo, error) {
+	if size < 0 {
+		spool, err := os.CreateTemp("", "blob-*")
+		if err != nil {
+			return BlobInfo{}, Internal(err)
+		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+
+		if size, err = io.Copy(spool, body); err != nil {
+			return BlobInfo{}, err
+		}
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return BlobInfo{}, Internal(err)
+		}
+		body = spool
+	}
+
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	response, err := store.do(ctx, http.MethodPut, key, body, size, header)
+	if err != nil {
+		return BlobInfo{}, Internal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return BlobInfo{}, s3Error(http.MethodPut, key, response)
+	}
+
+	return BlobInfo{
+		Key:         key,
+		Size:        size,
+		ContentType: contentType,
+		ModTime:     time.Now().UTC(),
+		ETag:        response.Header.Get("ETag"),
+	}, nil
+}
+
+func (store *s3BlobStore) Get(ctx context.Context, key string) (io.ReadSeekCloser, BlobInfo, error) {
+	response, err := store.do(ctx, http.MethodGet, key, nil, 0, nil)
+	if err != nil {
+		return nil, BlobInfo{}, Internal(err)
+	}
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		response.Body.Close()
+		return nil, BlobInfo{}, ErrBlobNotFound
+	default:
+		defer response.Body.Close()
+		return nil, BlobInfo{}, s3Error(http.MethodGet, key, response)
+	}
+
+	size, _ := strconv.ParseInt(response.Header.Get("Content-Length"), 10, 64)
+	modTime, _ := http.ParseTime(response.Header.Get("Last-Modified"))
+	object := &s3Object{ctx: ctx, store: store, key: key, size: size, body: response.Body}
+	return object, BlobInfo{
+		Key:         key,
+		Size:        size,
+		ContentType: response.Header.Get("Content-Type"),
+		ModTime:     modTime.UTC(),
+		ETag:        response.Header.Get("ETag"),
+	}, nil
+}
+
+// s3Object reads an object from offset on. A seek drops the open response
+// and the next read requests the rest of the object from the new offset.
+type s3Object struct {
+	ctx    context.Context
+	store  *s3BlobStore
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (object *s3Object) Read(p []byte) (int, error) {
+	if object.offset >= object.size {
+		return 0, io.EOF
+	}
+	if object.body == nil {
+		header := http.Header{"Range": {fmt.Sprintf("bytes=%d-", object.offset)}}
+		response, err := object.store.do(object.ctx, http.MethodGet, object.key, nil, 0, header)
+		if err != nil {
+			return 0, Internal(err)
+		}
+		switch response.StatusCode {
+		case http.StatusPartialContent:
+		case http.StatusOK:
+			// The server ignored the range, so skip to the offset ourselves
+			if _, err := io.CopyN(io.Discard, response.Body, object.offset); err != nil {
+				response.Body.Close()
+				return 0, Internal(err)
+			}
+		default:
+			defer response.Body.Close()
+			return 0, s3Error(http.MethodGet, object.key, response)
+		}
+		object.body = response.Body
+	}
+
+	n, err := object.body.Read(p)
+	object.offset += int64(n)
+	return n, err
+}
+
+func (object *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += object.offset
+	case io.SeekEnd:
+		offset += object.size
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("seek to negative offset %d", offset)
+	}
+	if offset != object.offset && object.body != nil {
+		object.body.Close()
+		object.body = nil
+	}
+	object.offset = offset
+	return offset, nil
+}
+
+func (object *s3Object) Close() error {
+	if object.body == nil {
+		return nil
+	}
+	return object.body.Close()
+}
+
+func (store *s3BlobStore) Delete(ctx context.Context, key string) error {
+	response, err := store.do(ctx, http.MethodDelete, key, nil, 0, nil)
+	if err != nil {
+		return Internal(err)
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return s3Error(http.MethodDelete, key, response)
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is an in-memory stand-in for an S3-compatible server. It checks
+// that requests are signed and supports the object calls BlobStore uses.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (fake *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") || r.Header.Get("X-Amz-Date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		if r.ContentLength < 0 {
+			w.WriteHeader(http.StatusLengthRequired)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		fake.objects[r.URL.Path] = body
+		fake.types[r.URL.Path] = r.Header.Get("Content-Type")
+		w.Header().Set("ETag", `"`+strconv.Itoa(len(body))+`"`)
+	case http.MethodGet:
+		body, ok := fake.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", fake.types[r.URL.Path])
+		status := http.StatusOK
+		if from := strings.TrimSuffix(strings.TrimPrefix(r.Header.Get("Range"), "bytes="), "-"); from != "" {
+			start, _ := strconv.Atoi(from)
+			body, status = body[start:], http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+		w.Write(body)
+	case http.MethodDelete:
+		delete(fake.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func testBlobStore(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+
+	info, err := store.Put(ctx, "videos/1/media/a", strings.NewReader("hello"), -1, "video/mp4")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), info.Size)
+
+	file, info, err := store.Get(ctx, "videos/1/media/a")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(file)
+	file.Close()
+	assert.Equal(t, "hello", string(body))
+	assert.Equal(t, int64(5), info.Size)
+
+	require.NoError(t, store.Delete(ctx, "videos/1/media/a"))
+	require.NoError(t, store.Delete(ctx, "videos/1/media/a"))
+	_, _, err = store.Get(ctx, "videos/1/media/a")
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocalBlobStore(root)
+	require.NoError(t, err)
+	testBlobStore(t, store)
+
+	path, err := store.(*localBlobStore).path("../../etc/passwd")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(path, root))
+}
+
+func TestS3BlobStore(t *testing.T) {
+	fake, server := newFakeS3(t)
+	store := NewS3BlobStore(S3Config{Endpoint: server.URL, Bucket: "media", AccessKeyID: "key", SecretAccessKey: "secret"})
+	testBlobStore(t, store)
+
+	_, err := store.Put(context.Background(), "a b", strings.NewReader("x"), 1, "")
+	require.NoError(t, err)
+	assert.Contains(t, fake.objects, "/media/a b")
+}
+
+func TestS3BlobStoreSeeks(t *testing.T) {
+	_, server := newFakeS3(t)
+	store := NewS3BlobStore(S3Config{Endpoint: server.URL, Bucket: "media", AccessKeyID: "key", SecretAccessKey: "secret"})
+	_, err := store.Put(context.Background(), "clip", strings.NewReader("0123456789"), 10, "video/mp4")
+	require.NoError(t, err)
+
+	object, _, err := store.Get(context.Background(), "clip")
+	require.NoError(t, err)
+	defer object.Close()
+
+	head := make([]byte, 2)
+	_, err = io.ReadFull(object, head)
+	require.NoError(t, err)
+	assert.Equal(t, "01", string(head))
+
+	_, err = object.Seek(-3, io.SeekEnd)
+	require.NoError(t, err)
+	tail, err := io.ReadAll(object)
+	require.NoError(t, err)
+	assert.Equal(t, "789", string(tail))
+}
+
+func TestSniffVideo(t *testing.T) {
+	mp4 := append([]byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom"), bytes.Repeat([]byte{0}, 5000)...)
+
+	detected, body, err := sniffVideo(bytes.NewReader(mp4))
+	require.NoError(t, err)
+	assert.Equal(t, "video/mp4", detected.String())
+	all, _ := io.ReadAll(body)
+	assert.Equal(t, mp4, all)
+
+	_, _, err = sniffVideo(strings.NewReader("just some text"))
+	assert.ErrorIs(t, err, Unsupported("unsupported_media", ""))
+
+	_, _, err = sniffVideo(strings.NewReader(""))
+	assert.ErrorIs(t, err, Validation("empty_media", ""))
+}
+
+func TestLimitedReader(t *testing.T) {
+	_, err := io.ReadAll(&limitedReader{reader: strings.NewReader("12345"), remaining: 5, err: ErrMediaTooLarge})
+	assert.NoError(t, err)
+
+	_, err = io.ReadAll(&limitedReader{reader: strings.NewReader("123456"), remaining: 5, err: ErrMediaTooLarge})
+	assert.ErrorIs(t, err, ErrMediaTooLarge)
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	entity "videoAPI/Entity"
+)
+
+// CaptionMatch is a cue found by CaptionStore.Search.
+type CaptionMatch struct {
+	VideoID    string `bson:"video_id"`
+	Language   string `bson:"language"`
+	entity.Cue `bson:",inline"`
+}
+
+// CaptionStore indexes the cues of caption tracks for search. The tracks
+// themselves are in the blob store.
+type CaptionStore interface {
+	// Replace sets the cues of the track of a video in one language.
+	Replace(videoID, language string, cues []entity.Cue) error
+	// Delete removes the cues of the track of a video in one language, or of
+	// all its tracks when language is "".
+	Delete(videoID, language string) error
+	// Search returns up to limit cues matching the words of query, best
+	// matches first.
+	Search(query string, limit int) ([]CaptionMatch, error)
+}
+
+type mongoCaptionStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoCaptionStore(collection *mongo.Collection) CaptionStore {
+	return &mongoCaptionStore{collection: collection}
+}
+
+// EnsureIndexes creates the text index searches run on. Tracks come in many
+// languages, so words are matched as written rather than stemmed; the
+// override field keeps Mongo from reading "language" as a stemming language.
+func (store *mongoCaptionStore) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := store.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "video_id", Value: 1}, {Key: "language", Value: 1}}},
+		{
+			Keys: bson.D{{Key: "text", Value: "text"}},
+			Options: options.Index().
+				SetName("caption_text").
+				SetDefaultLanguage("none").
+				SetLanguageOverride("text_language"),
+		},
+	})
+	return err
+}
+
+func (store *mongoCaptionStore) Replace(videoID, language string, cues []entity.Cue) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if _, err := store.collection.DeleteMany(ctx, bson.M{"video_id": videoID, "language": language}); err != nil {
+		return err
+	}
+	if len(cues) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, len(cues))
+	for i, cue := range cues {
+		documents[i] = CaptionMatch{VideoID: videoID, Language: language, Cue: cue}
+	}
+	_, err := store.collection.InsertMany(ctx, documents)
+	return err
+}
+
+func (store *mongoCaptionStore) Delete(videoID, language string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"video_id": videoID}
+	if language != "" {
+		filter["language"] = language
+	}
+	_, err := store.collection.DeleteMany(ctx, filter)
+	return err
+}
+
+func (store *mongoCaptionStore) Search(query string, limit int) ([]CaptionMatch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().
+		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}, {Key: "start", Value: 1}}).
+		SetLimit(int64(limit))
+	cursor, err := store.collection.Find(ctx, bson.M{"$text": bson.M{"$search": query}}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	matches := []CaptionMatch{}
+	if err := cursor.All(ctx, &matches); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+type memoryCaptionStore struct {
+	mu     sync.Mutex
+	tracks map[string][]CaptionMatch // by video ID and language
+}
+
+// NewMemoryCaptionStore keeps cues in process memory, for tests and
+// single-instance deployments without Mongo.
+func NewMemoryCaptionStore() CaptionStore {
+	return &memoryCaptionStore{tracks: map[string][]CaptionMatch{}}
+}
+
+func (store *memoryCaptionStore) Replace(videoID, language string, cues []entity.Cue) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	matches := make([]CaptionMatch, len(cues))
+	for i, cue := range cues {
+		matches[i] = CaptionMatch{VideoID: videoID, Language: language, Cue: cue}
+	}
+	store.tracks[videoID+"\x00"+language] = matches
+	return nil
+}
+
+func (store *memoryCaptionStore) Delete(videoID, language string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for key := range store.tracks {
+		if key == videoID+"\x00"+language || (language == "" && strings.HasPrefix(key, videoID+"\x00")) {
+			delete(store.tracks, key)
+		}
+	}
+	return nil
+}
+
+// Search ranks cues by how many of the words of query they contain.
+func (store *memoryCaptionStore) Search(query string, limit int) ([]CaptionMatch, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	words := searchWords(strings.ToLower(query))
+	type scored struct {
+		match CaptionMatch
+		score int
+	}
+	var found []scored
+	for _, matches := range store.tracks {
+		for _, match := range matches {
+			score := 0
+			text := searchWords(strings.ToLower(match.Text))
+			for _, word := range words {
+				if containsString(text, word) {
+					score++
+				}
+			}
+			if score > 0 {
+				found = append(found, scored{match, score})
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.match.VideoID != b.match.VideoID {
+			return a.match.VideoID < b.match.VideoID
+		}
+		return a.match.Start < b.match.Start
+	})
+
+	matches := []CaptionMatch{}
+	for i := 0; i < len(found) && i < limit; i++ {
+		matches = append(matches, found[i].match)
+	}
+	return matches, nil
+}
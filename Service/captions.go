@@ -0,0 +1,407 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	entity "videoAPI/Entity"
+)
+
+// MaxCaptionBytes bounds the size of an uploaded caption track. It is set
+// once at startup.
+var MaxCaptionBytes int64 = 1 << 20
+
+const (
+	// CaptionContentType is the type every caption track is stored in.
+	CaptionContentType = "text/vtt; charset=utf-8"
+
+	DefaultCaptionHits = 20
+	MaxCaptionHits     = 100
+
+	maxCues = 20000
+	// captionMatchesPerHit is how many cues are fetched for each hit asked
+	// for, to leave enough once cues of videos that are not public are
+	// dropped.
+	captionMatchesPerHit = 5
+)
+
+var (
+	ErrCaptionsTooLarge = TooLarge("captions_too_large", "Caption track is larger than the upload limit")
+	ErrCaptionsNotFound = NotFound("captions_not_found", "Video has no captions in that language")
+)
+
+var cueTags = regexp.MustCompile(`<[^>]*>`)
+
+func errInvalidCaptions(line int, format string, args ...interface{}) error {
+	return Validation("invalid_captions", fmt.Sprintf("Captions are not valid SRT or WebVTT: line %d: ", line)+fmt.Sprintf(format, args...))
+}
+
+// parseCaptions reads SRT or WebVTT captions, telling them apart by the
+// WEBVTT header.
+func parseCaptions(data []byte) ([]entity.Cue, error) {
+	if !utf8.Valid(data) {
+		return nil, Validation("invalid_captions", "Captions must be UTF-8 text")
+	}
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(text)
+	lines := strings.Split(text, "\n")
+
+	vtt := lines[0] == "WEBVTT" || strings.HasPrefix(lines[0], "WEBVTT ") || strings.HasPrefix(lines[0], "WEBVTT\t")
+
+	cues := []entity.Cue{}
+	first := true
+	for start := 0; start < len(lines); {
+		if strings.TrimSpace(lines[start]) == "" {
+			start++
+			continue
+		}
+		end := start
+		for end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+			end++
+		}
+		block, line := lines[start:end], start+1
+		start = end
+
+		if vtt && (first || isVTTMetadata(block[0])) {
+			first = false
+			continue
+		}
+		first = false
+
+		timing := 0
+		if !strings.Contains(block[0], "-->") {
+			timing = 1 // a cue identifier, or the counter of an SRT cue
+		}
+		if timing >= len(block) || !strings.Contains(block[timing], "-->") {
+			return nil, errInvalidCaptions(line, "expected a cue timing such as 00:00:01.000 --> 00:00:02.000")
+		}
+
+		cue, err := parseCueTiming(block[timing])
+		if err != nil {
+			return nil, errInvalidCaptions(line+timing, "%s", err)
+		}
+		if len(block) == timing+1 {
+			return nil, errInvalidCaptions(line+timing, "cue has no text")
+		}
+		for i, text := range block[timing+1:] {
+			// "-->" would read as a timing in WebVTT
+			block[timing+1+i] = strings.ReplaceAll(strings.TrimSpace(text), "-->", "--&gt;")
+		}
+		cue.Text = strings.Join(block[timing+1:], "\n")
+
+		if len(cues) == maxCues {
+			return nil, errInvalidCaptions(line, "more than %d cues", maxCues)
+		}
+		cues = append(cues, cue)
+	}
+
+	if len(cues) == 0 {
+		return nil, Validation("invalid_captions", "Captions have no cues")
+	}
+	return cues, nil
+}
+
+func isVTTMetadata(line string) bool {
+	for _, keyword := range []string{"NOTE", "STYLE", "REGION"} {
+		if line == keyword || strings.HasPrefix(line, keyword+" ") || strings.HasPrefix(line, keyword+"\t") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCueTiming reads a line such as "00:00:01,000 --> 00:00:02,500",
+// ignoring WebVTT cue settings and SRT coordinates after the end time.
+func parseCueTiming(line string) (entity.Cue, error) {
+	parts := strings.SplitN(line, "-->", 2)
+	after := strings.Fields(parts[1])
+	if len(after) == 0 {
+		return entity.Cue{}, errors.New("cue has no end time")
+	}
+
+	start, err := parseTimestamp(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return entity.Cue{}, err
+	}
+	end, err := parseTimestamp(after[0])
+	if err != nil {
+		return entity.Cue{}, err
+	}
+	if end <= start {
+		return entity.Cue{}, errors.New("cue ends before it starts")
+	}
+	return entity.Cue{Start: start, End: end}, nil
+}
+
+// parseTimestamp reads [hh:]mm:ss.mmm, with a comma as in SRT or a dot as in
+// WebVTT, as seconds.
+func parseTimestamp(s string) (float64, error) {
+	invalid := fmt.Errorf("invalid timestamp %q", s)
+
+	fields := strings.Split(strings.Replace(s, ",", ".", 1), ":")
+	if len(fields) < 2 || len(fields) > 3 {
+		return 0, invalid
+	}
+	seconds, fraction, ok := strings.Cut(fields[len(fields)-1], ".")
+	if !ok || len(fraction) == 0 || len(fraction) > 3 || len(seconds) != 2 {
+		return 0, invalid
+	}
+
+	var total float64
+	for i, field := range append(fields[:len(fields)-1], seconds) {
+		value, err := strconv.Atoi(field)
+		if err != nil || value < 0 || (i > 0 && value > 59) || (i == 0 && len(fields) == 2 && value > 59) {
+			return 0, invalid
+		}
+		total = total*60 + float64(value)
+	}
+	millis, err := strconv.Atoi(fraction + strings.Repeat("0", 3-len(fraction)))
+	if err != nil {
+		return 0, invalid
+	}
+	return total + float64(millis)/1000, nil
+}
+
+// formatTimestamp writes seconds as a WebVTT hh:mm:ss.mmm timestamp.
+func formatTimestamp(seconds float64) string {
+	millis := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", millis/3600000, millis/60000%60, millis/1000%60, millis%1000)
+}
+
+// formatVTT writes cues as a WebVTT file.
+func formatVTT(cues []entity.Cue) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for _, cue := range cues {
+		fmt.Fprintf(&b, "\n%s --> %s\n%s\n", formatTimestamp(cue.Start), formatTimestamp(cue.End), cue.Text)
+	}
+	return b.String()
+}
+
+// PutCaptions stores the SRT or WebVTT captions in body, as WebVTT, as the
+// track of the video with the given ID and version in the language of track,
+// replacing any it had. The other tracks are kept as read, so the video read
+// must be at version too.
+func (service *videoService) PutCaptions(id string, version int64, track entity.CaptionTrack, body io.Reader) (entity.Video, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	data, err := io.ReadAll(&limitedReader{reader: body, remaining: MaxCaptionBytes, err: ErrCaptionsTooLarge})
+	if err != nil {
+		return entity.Video{}, err
+	}
+	cues, err := parseCaptions(data)
+	if err != nil {
+		return entity.Video{}, err
+	}
+
+	existing, err := service.FindByID(id)
+	if err != nil {
+		return entity.Video{}, err
+	}
+	if existing.Version != version {
+		return entity.Video{}, ErrVersionMismatch
+	}
+
+	upload, err := newVideoID()
+	if err != nil {
+		return entity.Video{}, Internal(err)
+	}
+	track.Key = "videos/" + id + "/captions/" + track.Language + "/" + upload + ".vtt"
+	track.Cues = len(cues)
+	track.UpdatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	vtt := formatVTT(cues)
+	if _, err := service.blobs.Put(ctx, track.Key, strings.NewReader(vtt), int64(len(vtt)), CaptionContentType); err != nil {
+		return entity.Video{}, AsError(err)
+	}
+
+	tracks := []entity.CaptionTrack{}
+	var replaced *entity.CaptionTrack
+	for _, other := range existing.Captions {
+		if other.Language == track.Language {
+			previous := other
+			replaced = &previous
+			continue
+		}
+		tracks = append(tracks, other)
+	}
+	tracks = append(tracks, track)
+
+	updated, err := service.setServerFields(ctx, id, version, bson.M{"captions": tracks}, track.UpdatedAt)
+	if err != nil {
+		service.deleteBlob(ctx, track.Key)
+		return entity.Video{}, err
+	}
+	if replaced != nil {
+		service.deleteBlob(ctx, replaced.Key)
+	}
+
+	indexed := make([]entity.Cue, len(cues))
+	for i, cue := range cues {
+		cue.Text = cueTags.ReplaceAllString(cue.Text, "")
+		indexed[i] = cue
+	}
+	if err := service.captions.Replace(id, track.Language, indexed); err != nil {
+		fmt.Printf("Error indexing captions of %s in %s: %v\n", id, track.Language, err)
+	}
+
+	return updated, nil
+}
+
+// DeleteCaptions removes the track of the video with the given ID and
+// version in language. Like PutCaptions, it keeps the other tracks as read.
+func (service *videoService) DeleteCaptions(id string, version int64, language string) (entity.Video, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	existing, err := service.FindByID(id)
+	if err != nil {
+		return entity.Video{}, err
+	}
+	if existing.Version != version {
+		return entity.Video{}, ErrVersionMismatch
+	}
+	removed, err := FindCaptions(existing, language)
+	if err != nil {
+		return entity.Video{}, err
+	}
+
+	tracks := []entity.CaptionTrack{}
+	for _, other := range existing.Captions {
+		if other.Language != language {
+			tracks = append(tracks, other)
+		}
+	}
+
+	updated, err := service.setServerFields(ctx, id, version, bson.M{"captions": tracks}, time.Now().UTC().Truncate(time.Millisecond))
+	if err != nil {
+		return entity.Video{}, err
+	}
+
+	service.deleteBlob(ctx, removed.Key)
+	if err := service.captions.Delete(id, language); err != nil {
+		fmt.Printf("Error unindexing captions of %s in %s: %v\n", id, language, err)
+	}
+	return updated, nil
+}
+
+// FindCaptions returns the caption track of video in language.
+func FindCaptions(video entity.Video, language string) (entity.CaptionTrack, error) {
+	for _, track := range video.Captions {
+		if track.Language == language {
+			return track, nil
+		}
+	}
+	return entity.CaptionTrack{}, ErrCaptionsNotFound
+}
+
+// OpenCaptions opens the WebVTT track of video in language. The caller
+// closes it.
+func (service *videoService) OpenCaptions(video entity.Video, language string) (io.ReadSeekCloser, BlobInfo, error) {
+	track, err := FindCaptions(video, language)
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+
+	file, info, err := service.blobs.Get(context.Background(), track.Key)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			return nil, BlobInfo{}, ErrCaptionsNotFound
+		}
+		return nil, BlobInfo{}, err
+	}
+	info.ContentType = CaptionContentType
+	return file, info, nil
+}
+
+// SearchCaptions finds cues of public videos matching query, best matches
+// first, with the video each is in.
+func (service *videoService) SearchCaptions(query string, limit int) ([]entity.CaptionHit, error) {
+	words := searchWords(query)
+	if len(words) == 0 {
+		return nil, Validation("invalid_query", "q must contain a word")
+	}
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+	if limit < 1 || limit > MaxCaptionHits {
+		return nil, Validation("invalid_limit", fmt.Sprintf("limit must be between 1 and %d", MaxCaptionHits))
+	}
+
+	matches, err := service.captions.Search(strings.Join(words, " "), limit*captionMatchesPerHit)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	videos, err := service.publicVideos(matches)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := []entity.CaptionHit{}
+	for _, match := range matches {
+		video, ok := videos[match.VideoID]
+		if !ok {
+			continue
+		}
+		if len(hits) == limit {
+			break
+		}
+		hits = append(hits, entity.CaptionHit{Video: video, Language: match.Language, Cue: match.Cue})
+	}
+	return hits, nil
+}
+
+// publicVideos loads the live, public videos the matches are in.
+func (service *videoService) publicVideos(matches []CaptionMatch) (map[string]entity.Video, error) {
+	videos := map[string]entity.Video{}
+	if len(matches) == 0 {
+		return videos, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ids := []string{}
+	for _, match := range matches {
+		ids = append(ids, match.VideoID)
+	}
+	filter := live(bson.M{"id": bson.M{"$in": ids}, "visibility": entity.VisibilityPublic})
+	cursor, err := service.videoCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var video entity.Video
+		if err := cursor.Decode(&video); err != nil {
+			return nil, Internal(err)
+		}
+		videos[video.ID] = video
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, Internal(err)
+	}
+	return videos, nil
+}
+
+// deleteCaptions removes the caption tracks of video from storage.
+func (service *videoService) deleteCaptions(ctx context.Context, video entity.Video) {
+	for _, track := range video.Captions {
+		service.deleteBlob(ctx, track.Key)
+	}
+	if err := service.captions.Delete(video.ID, ""); err != nil {
+		fmt.Printf("Error unindexing captions of %s: %v\n", video.ID, err)
+	}
+}
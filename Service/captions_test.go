@@ -0,0 +1,89 @@
+package service
+
+import (
+	"testing"
+
+	entity "videoAPI/Entity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSRT(t *testing.T) {
+	srt := "\ufeff1\r\n00:00:01,000 --> 00:00:02,500\r\nHello <i>there</i>\r\n\r\n" +
+		"2\r\n00:01:02,040 --> 00:01:04,000 X1:10 X2:20\r\nSecond line\r\nwraps --> here\r\n"
+
+	cues, err := parseCaptions([]byte(srt))
+	require.NoError(t, err)
+	assert.Equal(t, []entity.Cue{
+		{Start: 1, End: 2.5, Text: "Hello <i>there</i>"},
+		{Start: 62.04, End: 64, Text: "Second line\nwraps --&gt; here"},
+	}, cues)
+
+	assert.Equal(t, "WEBVTT\n\n"+
+		"00:00:01.000 --> 00:00:02.500\nHello <i>there</i>\n\n"+
+		"00:01:02.040 --> 00:01:04.000\nSecond line\nwraps --&gt; here\n", formatVTT(cues))
+}
+
+func TestParseWebVTT(t *testing.T) {
+	vtt := "WEBVTT - Example\nKind: captions\n\n" +
+		"NOTE written by hand\nover two lines\n\n" +
+		"STYLE\n::cue { color: red }\n\n" +
+		"intro\n00:05.5 --> 00:07.000 align:start\nWelcome\n\n" +
+		"01:00:00.000 --> 01:00:01.000\nAn hour in\n"
+
+	cues, err := parseCaptions([]byte(vtt))
+	require.NoError(t, err)
+	assert.Equal(t, []entity.Cue{
+		{Start: 5.5, End: 7, Text: "Welcome"},
+		{Start: 3600, End: 3601, Text: "An hour in"},
+	}, cues)
+
+	again, err := parseCaptions([]byte(formatVTT(cues)))
+	require.NoError(t, err)
+	assert.Equal(t, cues, again)
+}
+
+func TestParseCaptionsRejects(t *testing.T) {
+	for name, captions := range map[string]string{
+		"empty":          "",
+		"header only":    "WEBVTT\n\n",
+		"no timing":      "1\nHello\n",
+		"bad timestamp":  "00:00:61,000 --> 00:01:02,000\nHello\n",
+		"backwards":      "00:00:05,000 --> 00:00:04,000\nHello\n",
+		"no text":        "00:00:01,000 --> 00:00:02,000\n",
+		"no end":         "00:00:01,000 -->\nHello\n",
+		"not utf-8":      "00:00:01,000 --> 00:00:02,000\n\xff\n",
+		"not captions":   "just some text",
+		"long fraction":  "00:00:01,0000 --> 00:00:02,000\nHello\n",
+		"minutes > 59":   "60:00.000 --> 61:00.000\nHello\n",
+		"missing digits": "0:1.000 --> 0:2.000\nHello\n",
+	} {
+		_, err := parseCaptions([]byte(captions))
+		assert.ErrorIs(t, err, Validation("invalid_captions", ""), name)
+	}
+}
+
+func TestMemoryCaptionStoreSearch(t *testing.T) {
+	store := NewMemoryCaptionStore()
+	require.NoError(t, store.Replace("v1", "en", []entity.Cue{
+		{Start: 1, End: 2, Text: "Knead the dough"},
+		{Start: 30, End: 32, Text: "Let the dough rest overnight"},
+	}))
+	require.NoError(t, store.Replace("v2", "en", []entity.Cue{{Start: 5, End: 6, Text: "Rest the meat"}}))
+	require.NoError(t, store.Replace("v2", "fr", []entity.Cue{{Start: 5, End: 6, Text: "Laisser reposer la pâte"}}))
+
+	matches, err := store.Search("dough rest", 10)
+	require.NoError(t, err)
+	require.Len(t, matches, 3)
+	assert.Equal(t, "Let the dough rest overnight", matches[0].Text, "both words rank first")
+	assert.Equal(t, 30.0, matches[0].Start)
+
+	matches, _ = store.Search("pâte", 10)
+	require.Len(t, matches, 1)
+	assert.Equal(t, CaptionMatch{VideoID: "v2", Language: "fr", Cue: entity.Cue{Start: 5, End: 6, Text: "Laisser reposer la pâte"}}, matches[0])
+
+	require.NoError(t, store.Delete("v2", ""))
+	matches, _ = store.Search("rest", 10)
+	assert.Len(t, matches, 1)
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	entity "videoAPI/Entity"
+)
+
+// MaxChapters bounds the chapters of a video.
+const MaxChapters = 100
+
+// chapterTitleMax is the longest title a chapter parsed from a description
+// keeps, matching the binding of entity.Chapter.
+const chapterTitleMax = 100
+
+// chapterLine matches description lines such as "00:00 Intro",
+// "1:02:03 - Q&A" or "(12:30) Wrap-up".
+var chapterLine = regexp.MustCompile(`^\s*\(?((?:\d{1,2}:)?\d{1,2}:\d{2})\)?\s*(?:[-–—:|]\s*)?(\S.*)$`)
+
+// parseClock reads [h:]mm:ss as seconds.
+func parseClock(clock string) (float64, bool) {
+	var seconds float64
+	fields := strings.Split(clock, ":")
+	for i, field := range fields {
+		value, err := strconv.Atoi(field)
+		if err != nil || (i > 0 && value > 59) {
+			return 0, false
+		}
+		seconds = seconds*60 + float64(value)
+	}
+	return seconds, true
+}
+
+// ChaptersFromDescription reads chapters from the timestamp lines of a
+// description. Like video sites that do the same, it only finds chapters
+// when there are at least two, the first starts at 0:00 and each starts
+// after the one before; anything else is taken for timestamps in prose.
+func ChaptersFromDescription(description string) []entity.Chapter {
+	var chapters []entity.Chapter
+	for _, line := range strings.Split(description, "\n") {
+		match := chapterLine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		start, ok := parseClock(match[1])
+		if !ok {
+			continue
+		}
+		title := strings.TrimSpace(match[2])
+		if utf8.RuneCountInString(title) > chapterTitleMax {
+			title = string([]rune(title)[:chapterTitleMax])
+		}
+		chapters = append(chapters, entity.Chapter{Start: start, Title: title})
+	}
+
+	if len(chapters) < 2 || len(chapters) > MaxChapters || chapters[0].Start != 0 {
+		return nil
+	}
+	for i := 1; i < len(chapters); i++ {
+		if chapters[i].Start <= chapters[i-1].Start {
+			return nil
+		}
+	}
+	return chapters
+}
+
+// Chapters returns the chapters of video: those set on it, or else those in
+// its description. Chapters starting after the end of the video, which can
+// happen when its duration is changed later, are left out.
+func Chapters(video entity.Video) []entity.Chapter {
+	chapters := video.Chapters
+	if len(chapters) == 0 {
+		chapters = ChaptersFromDescription(video.Description)
+	}
+
+	kept := []entity.Chapter{}
+	for _, chapter := range chapters {
+		if video.Duration > 0 && chapter.Start >= video.Duration {
+			break
+		}
+		kept = append(kept, chapter)
+	}
+	return kept
+}
+
+// checkChapters validates chapters, which must be in order of start, against
+// a video that runs for duration seconds, or for an unknown time when
+// duration is 0.
+func checkChapters(chapters []entity.Chapter, duration float64) error {
+	if len(chapters) > MaxChapters {
+		return InvalidFields([]FieldError{{Field: "chapters", Rule: "max", Message: fmt.Sprintf("a video has at most %d chapters", MaxChapters)}})
+	}
+
+	var fields []FieldError
+	for i, chapter := range chapters {
+		field := fmt.Sprintf("chapters[%d].start", i)
+		if duration > 0 && chapter.Start >= duration {
+			fields = append(fields, FieldError{Field: field, Rule: "duration", Message: field + " must be before the end of the video"})
+		}
+		if i > 0 && chapter.Start <= chapters[i-1].Start {
+			fields = append(fields, FieldError{Field: field, Rule: "ascending", Message: field + " must be after the start of the chapter before it"})
+		}
+	}
+	if len(fields) > 0 {
+		return InvalidFields(fields)
+	}
+	return nil
+}
+
+// PutChapters sets the chapters of the video with the given ID and version.
+// An empty list clears them, so the description is read for chapters again.
+// They are checked against the duration read, so the video read must be at
+// version too.
+func (service *videoService) PutChapters(id string, version int64, chapters []entity.Chapter) (entity.Video, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	existing, err := service.FindByID(id)
+	if err != nil {
+		return entity.Video{}, err
+	}
+	if existing.Version != version {
+		return entity.Video{}, ErrVersionMismatch
+	}
+
+	if err := checkChapters(chapters, existing.Duration); err != nil {
+		return entity.Video{}, err
+	}
+
+	return service.setServerFields(ctx, id, version, bson.M{"chapters": chapters}, time.Now().UTC().Truncate(time.Millisecond))
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+
+	entity "videoAPI/Entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChaptersFromDescription(t *testing.T) {
+	description := "Learn to bake bread.\n\n" +
+		"00:00 Intro\n" +
+		"1:30 - Mixing\n" +
+		"(12:05) Proofing: the long part\n" +
+		"1:02:03 | Baking\n" +
+		"\nThanks for watching!"
+
+	assert.Equal(t, []entity.Chapter{
+		{Start: 0, Title: "Intro"},
+		{Start: 90, Title: "Mixing"},
+		{Start: 725, Title: "Proofing: the long part"},
+		{Start: 3723, Title: "Baking"},
+	}, ChaptersFromDescription(description))
+
+	for name, description := range map[string]string{
+		"single timestamp":  "Skip to 2:30 for the good part\n00:00 Intro",
+		"not from zero":     "0:10 Intro\n1:00 Main",
+		"out of order":      "0:00 Intro\n5:00 Main\n2:00 Aside",
+		"no timestamps":     "Just a description",
+		"invalid seconds":   "0:00 Intro\n1:75 Main",
+		"timestamp in text": "We met at 10:30 and again at 11:00 sharp",
+	} {
+		assert.Nil(t, ChaptersFromDescription(description), name)
+	}
+}
+
+func TestChapters(t *testing.T) {
+	set := []entity.Chapter{{Start: 0, Title: "Start"}, {Start: 50, Title: "End"}}
+
+	video := entity.Video{Description: "00:00 Intro\n00:30 Main", Chapters: set}
+	assert.Equal(t, set, Chapters(video), "set chapters win over the description")
+
+	video.Chapters = nil
+	assert.Equal(t, []entity.Chapter{{Start: 0, Title: "Intro"}, {Start: 30, Title: "Main"}}, Chapters(video))
+
+	video.Duration = 20
+	assert.Equal(t, []entity.Chapter{{Start: 0, Title: "Intro"}}, Chapters(video), "past the end")
+
+	assert.Equal(t, []entity.Chapter{}, Chapters(entity.Video{}))
+}
+
+func TestCheckChapters(t *testing.T) {
+	assert.NoError(t, checkChapters([]entity.Chapter{{Start: 0, Title: "a"}, {Start: 10, Title: "b"}}, 60))
+	assert.NoError(t, checkChapters([]entity.Chapter{{Start: 500, Title: "a"}}, 0), "duration unknown")
+	assert.NoError(t, checkChapters(nil, 60))
+
+	err := checkChapters([]entity.Chapter{{Start: 10, Title: "a"}, {Start: 10, Title: "b"}, {Start: 60, Title: "c"}}, 60)
+	var serviceErr *Error
+	if assert.ErrorAs(t, err, &serviceErr) {
+		assert.Equal(t, []FieldError{
+			{Field: "chapters[1].start", Rule: "ascending", Message: "chapters[1].start must be after the start of the chapter before it"},
+			{Field: "chapters[2].start", Rule: "duration", Message: "chapters[2].start must be before the end of the video"},
+		}, serviceErr.Fields)
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import "errors"
+
+// Kind classifies a domain error so that transports can map it to a response
+// without knowing anything about the storage layer underneath.
+type Kind string
+
+const (
+	KindNotFound     Kind = "not_found"
+	KindConflict     Kind = "conflict"
+	KindValidation   Kind = "validation"
+	KindUnauthorized Kind = "unauthorized"
+	KindForbidden    Kind = "forbidden"
+	KindPrecondition Kind = "precondition_failed"
+	KindUnsupported  Kind = "unsupported_media_type"
+	KindTooLarge     Kind = "payload_too_large"
+	KindInternal     Kind = "internal"
+)
+
+// Error is the error type returned by the service layer. Code is a stable,
+// machine-readable identifier; Message is safe to show to clients. Err holds
+// the underlying cause and is never exposed outside the server.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+// FieldError describes one invalid field of a request. Field is the JSON
+// name of the field and Rule the validation tag that failed.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors by code, so errors.Is(err, ErrVideoNotFound) holds for
+// any error carrying the same code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+var (
+	ErrVideoNotFound      = NotFound("video_not_found", "Video not found")
+	ErrVideoNotInTrash    = NotFound("video_not_in_trash", "Video not found in trash")
+	ErrVideoExists        = Conflict("video_exists", "Video ID already exists")
+	ErrVersionMismatch    = Precondition("version_mismatch", "Video has been modified since it was read")
+	ErrNotVideoOwner      = Forbidden("not_video_owner", "Only the owner of the video or an admin can change it")
+	ErrUserNotFound       = NotFound("user_not_found", "User not found")
+	ErrUserExists         = Conflict("user_exists", "User already exists")
+	ErrInvalidCredentials = Unauthorized("invalid_credentials", "Invalid email or password")
+	ErrInvalidRole        = Validation("invalid_role", "Role must be \"admin\" or empty")
+)
+
+func NotFound(code, message string) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Message: message}
+}
+
+func Conflict(code, message string) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: message}
+}
+
+func Validation(code, message string) *Error {
+	return &Error{Kind: KindValidation, Code: code, Message: message}
+}
+
+// InvalidFields reports a validation failure on one or more fields.
+func InvalidFields(fields []FieldError) *Error {
+	return &Error{Kind: KindValidation, Code: "validation_failed", Message: "Validation failed", Fields: fields}
+}
+
+func Unauthorized(code, message string) *Error {
+	return &Error{Kind: KindUnauthorized, Code: code, Message: message}
+}
+
+func Unsupported(code, message string) *Error {
+	return &Error{Kind: KindUnsupported, Code: code, Message: message}
+}
+
+func TooLarge(code, message string) *Error {
+	return &Error{Kind: KindTooLarge, Code: code, Message: message}
+}
+
+func Forbidden(code, message string) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Message: message}
+}
+
+func Precondition(code, message string) *Error {
+	return &Error{Kind: KindPrecondition, Code: code, Message: message}
+}
+
+// Internal wraps an unexpected error, typically from Mongo or Redis. The
+// cause is kept for logging but the message is generic.
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Code: "internal_error", Message: "Internal server error", Err: err}
+}
+
+// AsError returns err as a domain error, wrapping it with Internal if it is
+// not one already.
+func AsError(err error) *Error {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr
+	}
+	return Internal(err)
+}
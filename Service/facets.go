@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	entity "videoAPI/Entity"
+)
+
+const (
+	facetLimit    = 20
+	facetCacheTTL = time.Minute
+)
+
+// durationBuckets are the lower bounds, in seconds, of the duration facet
+// buckets. Videos of an hour or more fall into the last, open bucket.
+var durationBuckets = []struct {
+	lower float64
+	label string
+}{
+	{0, "<1m"},
+	{60, "1-5m"},
+	{300, "5-20m"},
+	{1200, "20-60m"},
+}
+
+const longDurationBucket = "60m+"
+
+// facetCacheKey identifies a search by its parsed query and filter, so that
+// requests differing only in spelling or parameter order share an entry.
+func facetCacheKey(search searchQuery, filter VideoFilter, fuzzy bool) string {
+	lower := func(values []string) []string {
+		lowered := make([]string, len(values))
+		for i, value := range values {
+			lowered[i] = strings.ToLower(value)
+		}
+		return lowered
+	}
+	normalized, _ := json.Marshal(struct {
+		Terms    []string
+		Phrases  []string
+		Prefixes []string
+		Filter   VideoFilter
+		Fuzzy    bool
+	}{lower(search.Terms), lower(search.Phrases), lower(search.Prefixes), filter, fuzzy})
+
+	sum := sha1.Sum(normalized)
+	return "facets:" + hex.EncodeToString(sum[:])
+}
+
+func facetPipeline(filter bson.M) mongo.Pipeline {
+	countBy := func(field string) bson.A {
+		return bson.A{
+			bson.M{"$match": bson.M{field: bson.M{"$nin": bson.A{"", nil}}}},
+			bson.M{"$sortByCount": "$" + field},
+			bson.M{"$limit": facetLimit},
+		}
+	}
+
+	boundaries := bson.A{}
+	for _, bucket := range durationBuckets {
+		boundaries = append(boundaries, bucket.lower)
+	}
+	boundaries = append(boundaries, 3600)
+
+	return mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$facet", Value: bson.M{
+			"tags": bson.A{
+				bson.M{"$unwind": "$tags"},
+				bson.M{"$sortByCount": "$tags"},
+				bson.M{"$limit": facetLimit},
+			},
+			"categories": countBy("category"),
+			"languages":  countBy("language"),
+			"durations": bson.A{
+				bson.M{"$bucket": bson.M{
+					"groupBy":    "$duration",
+					"boundaries": boundaries,
+					"default":    longDurationBucket,
+					"output":     bson.M{"count": bson.M{"$sum": 1}},
+				}},
+			},
+		}}},
+	}
+}
+
+// durationLabel names a $bucket _id, which is the lower bound of the bucket
+// or the default label.
+func durationLabel(id interface{}) string {
+	var lower float64
+	switch value := id.(type) {
+	case string:
+		return value
+	case int32:
+		lower = float64(value)
+	case int64:
+		lower = float64(value)
+	case float64:
+		lower = value
+	}
+	for _, bucket := range durationBuckets {
+		if bucket.lower == lower {
+			return bucket.label
+		}
+	}
+	return fmt.Sprint(id)
+}
+
+// facets counts the matches of filter, reading through a short-lived cache.
+// Counts may lag writes by up to facetCacheTTL.
+func (service *videoService) facets(ctx context.Context, filter bson.M, cacheKey string) (*entity.Facets, error) {
+	cached, err := service.redis.Get(ctx, cacheKey).Result()
+	if err == nil {
+		var facets entity.Facets
+		if err := json.Unmarshal([]byte(cached), &facets); err == nil {
+			return &facets, nil
+		}
+	}
+
+	cursor, err := service.videoCollection.Aggregate(ctx, facetPipeline(filter))
+	if err != nil {
+		return nil, Internal(err)
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		entity.Facets `bson:",inline"`
+		Durations     []struct {
+			ID    interface{} `bson:"_id"`
+			Count int64       `bson:"count"`
+		} `bson:"durations"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, Internal(err)
+	}
+
+	facets := entity.Facets{Tags: []entity.FacetCount{}, Categories: []entity.FacetCount{}, Languages: []entity.FacetCount{}, Durations: []entity.FacetCount{}}
+	if len(results) > 0 {
+		result := results[0]
+		for _, list := range []*[]entity.FacetCount{&result.Tags, &result.Categories, &result.Languages} {
+			if *list == nil {
+				*list = []entity.FacetCount{}
+			}
+		}
+		facets.Tags, facets.Categories, facets.Languages = result.Tags, result.Categories, result.Languages
+		for _, bucket := range result.Durations {
+			facets.Durations = append(facets.Durations, entity.FacetCount{Value: durationLabel(bucket.ID), Count: bucket.Count})
+		}
+	}
+
+	jsonFacets, _ := json.Marshal(facets)
+	if err := service.redis.Set(ctx, cacheKey, jsonFacets, facetCacheTTL).Err(); err != nil {
+		fmt.Printf("Error caching facets in Redis: %v", err)
+	}
+
+	return &facets, nil
+}
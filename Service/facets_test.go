@@ -0,0 +1,25 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFacetCacheKeyNormalizesQuery(t *testing.T) {
+	filter := VideoFilter{Tag: "go", Category: "talks"}
+
+	key := facetCacheKey(parseSearchQuery(`Gophers  "Go Tour"`), filter, false)
+	assert.Equal(t, key, facetCacheKey(parseSearchQuery(`gophers "go tour"`), filter, false))
+	assert.Equal(t, key, facetCacheKey(parseSearchQuery(`gophers, "go tour"!`), filter, false))
+	assert.NotEqual(t, key, facetCacheKey(parseSearchQuery(`gophers`), filter, false))
+	assert.NotEqual(t, key, facetCacheKey(parseSearchQuery(`Gophers "Go Tour"`), filter, true))
+	assert.NotEqual(t, key, facetCacheKey(parseSearchQuery(`Gophers "Go Tour"`), VideoFilter{Tag: "go"}, false))
+}
+
+func TestDurationLabel(t *testing.T) {
+	assert.Equal(t, "<1m", durationLabel(int32(0)))
+	assert.Equal(t, "1-5m", durationLabel(int64(60)))
+	assert.Equal(t, "20-60m", durationLabel(1200.0))
+	assert.Equal(t, longDurationBucket, durationLabel(longDurationBucket))
+}
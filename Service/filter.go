@@ -0,0 +1,240 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	entity "videoAPI/Entity"
+)
+
+// VideoFilter narrows a video listing. It is storage-neutral: Mongo reads it
+// through mongo(), other backends and in-process indexes through Matches.
+// Zero values match everything.
+type VideoFilter struct {
+	Tag           string
+	Category      string
+	Owner         string
+	Language      string
+	Visibility    string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	DurationLT    *float64
+	DurationGT    *float64
+	LinkState     string
+	// ListedOnly leaves out videos that are not public, other than those
+	// owned by Viewer. Listings set it for everyone but admins.
+	ListedOnly bool
+	Viewer     string
+}
+
+// Matches reports whether video passes the filter.
+func (filter VideoFilter) Matches(video entity.Video) bool {
+	if filter.Tag != "" && !containsString(video.Tags, filter.Tag) {
+		return false
+	}
+	if filter.Category != "" && video.Category != filter.Category {
+		return false
+	}
+	if filter.Owner != "" && video.Owner != filter.Owner {
+		return false
+	}
+	if filter.Language != "" && video.Language != filter.Language {
+		return false
+	}
+	if filter.Visibility != "" && video.Visibility != filter.Visibility {
+		return false
+	}
+	if !filter.CreatedAfter.IsZero() && !video.CreatedAt.After(filter.CreatedAfter) {
+		return false
+	}
+	if !filter.CreatedBefore.IsZero() && !video.CreatedAt.Before(filter.CreatedBefore) {
+		return false
+	}
+	if filter.DurationLT != nil && !(video.Duration < *filter.DurationLT) {
+		return false
+	}
+	if filter.DurationGT != nil && !(video.Duration > *filter.DurationGT) {
+		return false
+	}
+	if filter.LinkState != "" && (video.LinkStatus == nil || video.LinkStatus.State != filter.LinkState) {
+		return false
+	}
+	if filter.ListedOnly && video.Visibility != entity.VisibilityPublic && (filter.Viewer == "" || video.Owner != filter.Viewer) {
+		return false
+	}
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// mongo adds the filter's conditions to base. Values are only ever used as
+// operands, never as operators or patterns.
+func (filter VideoFilter) mongo(base bson.M) bson.M {
+	equals := map[string]string{
+		"tags":              filter.Tag,
+		"category":          filter.Category,
+		"owner":             filter.Owner,
+		"language":          filter.Language,
+		"visibility":        filter.Visibility,
+		"link_status.state": filter.LinkState,
+	}
+	for field, value := range equals {
+		if value != "" {
+			base[field] = bson.M{"$eq": value}
+		}
+	}
+
+	created := bson.M{}
+	if !filter.CreatedAfter.IsZero() {
+		created["$gt"] = filter.CreatedAfter
+	}
+	if !filter.CreatedBefore.IsZero() {
+		created["$lt"] = filter.CreatedBefore
+	}
+	if len(created) > 0 {
+		base["created_at"] = created
+	}
+
+	duration := bson.M{}
+	if filter.DurationLT != nil {
+		duration["$lt"] = *filter.DurationLT
+	}
+	if filter.DurationGT != nil {
+		duration["$gt"] = *filter.DurationGT
+	}
+	if len(duration) > 0 {
+		base["duration"] = duration
+	}
+
+	if filter.ListedOnly {
+		listed := bson.M{"visibility": entity.VisibilityPublic}
+		if filter.Viewer != "" {
+			listed = bson.M{"$or": []bson.M{listed, {"owner": bson.M{"$eq": filter.Viewer}}}}
+		}
+		// Text searches may already have conditions of their own here
+		and, _ := base["$and"].([]bson.M)
+		base["$and"] = append(and, listed)
+	}
+
+	return base
+}
+
+// sortField describes a field listings may be sorted by. key renders the
+// field of a video for a page cursor and parse reads it back.
+type sortField struct {
+	key   func(entity.Video) string
+	parse func(string) (interface{}, error)
+}
+
+var sortFields = map[string]sortField{
+	"title": {
+		key:   func(video entity.Video) string { return video.Title },
+		parse: func(s string) (interface{}, error) { return s, nil },
+	},
+	"created_at": {
+		key: func(video entity.Video) string { return video.CreatedAt.Format(time.RFC3339Nano) },
+		parse: func(s string) (interface{}, error) {
+			return time.Parse(time.RFC3339Nano, s)
+		},
+	},
+	"views": {
+		key: func(video entity.Video) string { return strconv.FormatInt(video.Views, 10) },
+		parse: func(s string) (interface{}, error) {
+			return strconv.ParseInt(s, 10, 64)
+		},
+	},
+}
+
+// sortSpec is a parsed sort parameter such as "-created_at" or "title:asc".
+type sortSpec struct {
+	Field      string
+	Descending bool
+}
+
+// parseSort accepts "field", "-field", "field:asc" and "field:desc" for the
+// fields in sortFields. An empty string is the default ordering.
+func parseSort(raw string) (sortSpec, error) {
+	if raw == "" {
+		return sortSpec{}, nil
+	}
+
+	spec := sortSpec{Field: raw}
+	if strings.HasPrefix(raw, "-") {
+		spec = sortSpec{Field: raw[1:], Descending: true}
+	} else if field, direction, found := strings.Cut(raw, ":"); found {
+		spec.Field = field
+		switch direction {
+		case "asc":
+		case "desc":
+			spec.Descending = true
+		default:
+			return sortSpec{}, InvalidFields([]FieldError{{Field: "sort", Rule: "oneof", Message: "sort direction must be asc or desc"}})
+		}
+	}
+
+	if _, ok := sortFields[spec.Field]; !ok {
+		return sortSpec{}, InvalidFields([]FieldError{{Field: "sort", Rule: "oneof", Message: "sort must be one of title, created_at, views"}})
+	}
+	return spec, nil
+}
+
+// String is the canonical form of the spec, used to tie cursors to it.
+func (spec sortSpec) String() string {
+	if spec.Field == "" {
+		return "id"
+	}
+	if spec.Descending {
+		return "-" + spec.Field
+	}
+	return spec.Field
+}
+
+// mongoSort orders by the field, then by id so that the order is total.
+func (spec sortSpec) mongoSort() bson.D {
+	if spec.Field == "" {
+		return bson.D{{Key: "id", Value: 1}}
+	}
+	direction := 1
+	if spec.Descending {
+		direction = -1
+	}
+	return bson.D{{Key: spec.Field, Value: direction}, {Key: "id", Value: direction}}
+}
+
+func (spec sortSpec) cursorFor(video entity.Video) pageCursor {
+	cursor := pageCursor{Sort: spec.String(), ID: video.ID}
+	if spec.Field != "" {
+		cursor.Value = sortFields[spec.Field].key(video)
+	}
+	return cursor
+}
+
+// after selects the videos that follow cursor in this ordering.
+func (spec sortSpec) after(cursor pageCursor) (bson.M, error) {
+	if spec.Field == "" {
+		return bson.M{"id": bson.M{"$gt": cursor.ID}}, nil
+	}
+
+	value, err := sortFields[spec.Field].parse(cursor.Value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	op := "$gt"
+	if spec.Descending {
+		op = "$lt"
+	}
+	return bson.M{"$or": []bson.M{
+		{spec.Field: bson.M{op: value}},
+		{spec.Field: value, "id": bson.M{op: cursor.ID}},
+	}}, nil
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	entity "videoAPI/Entity"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestVideoFilter(t *testing.T) {
+	limit := 60.0
+	filter := VideoFilter{Tag: "go", Owner: "a@b.c", DurationLT: &limit, CreatedAfter: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	assert.Equal(t, bson.M{
+		"tags":       bson.M{"$eq": "go"},
+		"owner":      bson.M{"$eq": "a@b.c"},
+		"duration":   bson.M{"$lt": 60.0},
+		"created_at": bson.M{"$gt": filter.CreatedAfter},
+	}, filter.mongo(bson.M{}))
+
+	video := entity.Video{Tags: []string{"go"}, Owner: "a@b.c", Duration: 30, CreatedAt: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}
+	assert.True(t, filter.Matches(video))
+	video.Duration = 60
+	assert.False(t, filter.Matches(video))
+}
+
+func TestVideoFilterKeepsOperatorsOut(t *testing.T) {
+	filter := VideoFilter{Owner: `{"$ne": ""}`}
+
+	assert.Equal(t, bson.M{"owner": bson.M{"$eq": `{"$ne": ""}`}}, filter.mongo(bson.M{}))
+}
+
+func TestVideoFilterListedOnly(t *testing.T) {
+	anonymous := VideoFilter{ListedOnly: true}
+	assert.Equal(t, bson.M{"$and": []bson.M{{"visibility": entity.VisibilityPublic}}}, anonymous.mongo(bson.M{}))
+
+	user := VideoFilter{ListedOnly: true, Viewer: "a@b.c"}
+	assert.Equal(t, bson.M{"$and": []bson.M{
+		{"title": "x"},
+		{"$or": []bson.M{{"visibility": entity.VisibilityPublic}, {"owner": bson.M{"$eq": "a@b.c"}}}},
+	}}, user.mongo(bson.M{"$and": []bson.M{{"title": "x"}}}))
+
+	private := entity.Video{Owner: "a@b.c", Visibility: entity.VisibilityPrivate}
+	assert.False(t, anonymous.Matches(private))
+	assert.True(t, user.Matches(private))
+	assert.False(t, VideoFilter{ListedOnly: true, Viewer: "x@y.z"}.Matches(private))
+	assert.True(t, VideoFilter{}.Matches(private))
+}
+
+func TestParseSort(t *testing.T) {
+	spec, err := parseSort("-created_at")
+	assert.NoError(t, err)
+	assert.Equal(t, sortSpec{Field: "created_at", Descending: true}, spec)
+
+	spec, err = parseSort("title:asc")
+	assert.NoError(t, err)
+	assert.Equal(t, "title", spec.String())
+
+	_, err = parseSort("password")
+	assert.Equal(t, "validation_failed", err.(*Error).Code)
+	_, err = parseSort("title:sideways")
+	assert.Error(t, err)
+}
+
+func TestSortCursor(t *testing.T) {
+	spec, _ := parseSort("-views")
+	cursor := spec.cursorFor(entity.Video{ID: "v1", Views: 42})
+
+	decoded, err := decodeCursor(encodeCursor(cursor), "-views")
+	assert.NoError(t, err)
+
+	after, err := spec.after(decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"$or": []bson.M{
+		{"views": bson.M{"$lt": int64(42)}},
+		{"views": int64(42), "id": bson.M{"$lt": "v1"}},
+	}}, after)
+
+	_, err = decodeCursor(encodeCursor(cursor), "views")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
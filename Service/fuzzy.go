@@ -0,0 +1,283 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	entity "videoAPI/Entity"
+)
+
+// maxFuzzyMatches bounds how many videos a fuzzy search ranks.
+const maxFuzzyMatches = 1000
+
+// fuzzyIndex finds title and tag words within a small edit distance of a
+// query word. Words sharing a trigram with the query word are the only
+// candidates measured, so a search touches a small part of the vocabulary.
+//
+// Like suggestIndex it is local to the process and loaded by
+// LoadSearchIndexes.
+type fuzzyIndex struct {
+	mu       sync.RWMutex
+	videos   map[string][]string
+	words    map[string]map[string]bool
+	trigrams map[string]map[string]bool
+}
+
+func newFuzzyIndex() *fuzzyIndex {
+	return &fuzzyIndex{
+		videos:   map[string][]string{},
+		words:    map[string]map[string]bool{},
+		trigrams: map[string]map[string]bool{},
+	}
+}
+
+// trigrams returns the three-rune windows of word padded with spaces, so
+// that the first and last letters count for as much as the ones between.
+func trigrams(word string) []string {
+	runes := []rune("  " + word + " ")
+	grams := make([]string, 0, len(runes)-2)
+	for i := 0; i+3 <= len(runes); i++ {
+		grams = append(grams, string(runes[i:i+3]))
+	}
+	return grams
+}
+
+// maxEdits is how many typos a word of the given length tolerates.
+func maxEdits(length int) int {
+	switch {
+	case length <= 2:
+		return 0
+	case length <= 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance is the Levenshtein distance between a and b, counting an
+// adjacent transposition as one edit.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	rows := make([][]int, len(s)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(t)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			best := rows[i-1][j-1] + cost
+			if rows[i-1][j]+1 < best {
+				best = rows[i-1][j] + 1
+			}
+			if rows[i][j-1]+1 < best {
+				best = rows[i][j-1] + 1
+			}
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] && rows[i-2][j-2]+1 < best {
+				best = rows[i-2][j-2] + 1
+			}
+			rows[i][j] = best
+		}
+	}
+	return rows[len(s)][len(t)]
+}
+
+func videoWords(video entity.Video) []string {
+	seen := map[string]bool{}
+	words := []string{}
+	for _, text := range append([]string{video.Title}, video.Tags...) {
+		for _, word := range searchWords(strings.ToLower(text)) {
+			if !seen[word] {
+				seen[word] = true
+				words = append(words, word)
+			}
+		}
+	}
+	return words
+}
+
+// put indexes the title and tags of video, replacing any earlier entry.
+// Deleted videos are removed instead.
+func (index *fuzzyIndex) put(video entity.Video) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	index.remove(video.ID)
+	if video.DeletedAt != nil {
+		return
+	}
+
+	words := videoWords(video)
+	index.videos[video.ID] = words
+	for _, word := range words {
+		if index.words[word] == nil {
+			index.words[word] = map[string]bool{}
+			for _, gram := range trigrams(word) {
+				if index.trigrams[gram] == nil {
+					index.trigrams[gram] = map[string]bool{}
+				}
+				index.trigrams[gram][word] = true
+			}
+		}
+		index.words[word][video.ID] = true
+	}
+}
+
+func (index *fuzzyIndex) delete(id string) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.remove(id)
+}
+
+// remove drops id, and any word no other video uses, from the index. The
+// caller holds the write lock.
+func (index *fuzzyIndex) remove(id string) {
+	for _, word := range index.videos[id] {
+		delete(index.words[word], id)
+		if len(index.words[word]) > 0 {
+			continue
+		}
+		delete(index.words, word)
+		for _, gram := range trigrams(word) {
+			delete(index.trigrams[gram], word)
+			if len(index.trigrams[gram]) == 0 {
+				delete(index.trigrams, gram)
+			}
+		}
+	}
+	delete(index.videos, id)
+}
+
+// replace swaps the whole index for one built from videos.
+func (index *fuzzyIndex) replace(videos []entity.Video) {
+	fresh := newFuzzyIndex()
+	for _, video := range videos {
+		fresh.put(video)
+	}
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.videos, index.words, index.trigrams = fresh.videos, fresh.words, fresh.trigrams
+}
+
+// fuzzyMatches is the result of a fuzzy search: a score per video and the
+// indexed words that matched, for highlighting.
+type fuzzyMatches struct {
+	Scores map[string]float64
+	Words  []string
+}
+
+// ids returns the matching video IDs, best first, at most maxFuzzyMatches.
+func (matches fuzzyMatches) ids() []string {
+	ids := make([]string, 0, len(matches.Scores))
+	for id := range matches.Scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if matches.Scores[ids[i]] != matches.Scores[ids[j]] {
+			return matches.Scores[ids[i]] > matches.Scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > maxFuzzyMatches {
+		ids = ids[:maxFuzzyMatches]
+	}
+	return ids
+}
+
+// match scores every video with a word close to one of the query words. Each
+// query word adds the similarity, 1 for an exact match, of the closest word
+// of the video, so videos matching more of the query rank first.
+func (index *fuzzyIndex) match(query []string) fuzzyMatches {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	matches := fuzzyMatches{Scores: map[string]float64{}, Words: []string{}}
+	for _, term := range query {
+		term = strings.ToLower(term)
+		length := utf8.RuneCountInString(term)
+
+		best := map[string]float64{}
+		for word := range index.candidates(term) {
+			distance := editDistance(term, word)
+			if distance > maxEdits(length) {
+				continue
+			}
+			matches.Words = append(matches.Words, word)
+
+			longest := length
+			if n := utf8.RuneCountInString(word); n > longest {
+				longest = n
+			}
+			similarity := 1 - float64(distance)/float64(longest)
+			for id := range index.words[word] {
+				if similarity > best[id] {
+					best[id] = similarity
+				}
+			}
+		}
+		for id, similarity := range best {
+			matches.Scores[id] += similarity
+		}
+	}
+
+	sort.Strings(matches.Words)
+	return matches
+}
+
+// candidates returns the indexed words sharing at least one trigram with
+// term. The caller holds the read lock.
+func (index *fuzzyIndex) candidates(term string) map[string]bool {
+	words := map[string]bool{}
+	for _, gram := range trigrams(term) {
+		for word := range index.trigrams[gram] {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// fuzzyMatch runs the fuzzy search requested, or returns nil when the
+// request is not fuzzy or has no words to match.
+func (service *videoService) fuzzyMatch(request SearchRequest) *fuzzyMatches {
+	if !request.Fuzzy {
+		return nil
+	}
+	words := searchWords(request.Query)
+	if len(words) == 0 {
+		return nil
+	}
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+	matches := service.fuzzy.match(words)
+	return &matches
+}
+
+// fuzzyPage orders results by score and returns at most limit of them,
+// starting at skip.
+func fuzzyPage(results []entity.SearchResult, skip, limit int) []entity.SearchResult {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if skip >= len(results) {
+		return []entity.SearchResult{}
+	}
+	results = results[skip:]
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	entity "videoAPI/Entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("gopher", "gopher"))
+	assert.Equal(t, 1, editDistance("gopher", "gohper"))
+	assert.Equal(t, 1, editDistance("gopher", "gophers"))
+	assert.Equal(t, 2, editDistance("gopher", "goofer"))
+	assert.Equal(t, 3, editDistance("", "abc"))
+}
+
+func TestFuzzyIndexToleratesTypos(t *testing.T) {
+	index := newFuzzyIndex()
+	index.put(entity.Video{ID: "1", Title: "Concurrency in Go", Tags: []string{"goroutines"}})
+	index.put(entity.Video{ID: "2", Title: "Concurrency patterns"})
+	index.put(entity.Video{ID: "3", Title: "Cooking pasta"})
+
+	matches := index.match([]string{"Concurency", "gorutines"})
+	assert.Equal(t, []string{"1", "2"}, matches.ids())
+	assert.Equal(t, []string{"concurrency", "goroutines"}, matches.Words)
+
+	assert.Empty(t, index.match([]string{"cat"}).Scores)
+	assert.Empty(t, index.match([]string{"pizza"}).Scores)
+}
+
+func TestFuzzyIndexFollowsWrites(t *testing.T) {
+	index := newFuzzyIndex()
+	index.put(entity.Video{ID: "1", Title: "Concurrency in Go"})
+	index.put(entity.Video{ID: "1", Title: "Cooking pasta"})
+	assert.Empty(t, index.match([]string{"concurrency"}).Scores)
+	assert.NotEmpty(t, index.match([]string{"pasta"}).Scores)
+
+	deleted := time.Now()
+	index.put(entity.Video{ID: "1", Title: "Cooking pasta", DeletedAt: &deleted})
+	assert.Empty(t, index.words)
+	assert.Empty(t, index.trigrams)
+}
+
+func TestFuzzyPage(t *testing.T) {
+	results := []entity.SearchResult{
+		{Video: entity.Video{ID: "a"}, Score: 1},
+		{Video: entity.Video{ID: "b"}, Score: 2},
+		{Video: entity.Video{ID: "c"}, Score: 1.5},
+	}
+
+	page := fuzzyPage(results, 1, 5)
+	assert.Equal(t, []string{"c", "a"}, []string{page[0].ID, page[1].ID})
+	assert.Empty(t, fuzzyPage(results, 3, 5))
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	entity "videoAPI/Entity"
+)
+
+const PlaylistContentType = "application/vnd.apple.mpegurl"
+
+var (
+	ErrRenditionNotFound = NotFound("rendition_not_found", "Rendition not found")
+	ErrSegmentNotFound   = NotFound("segment_not_found", "Segment not found")
+)
+
+// segmentTypes are the segment files a rendition may list, by extension.
+// Playlists are version 3, which has no EXT-X-MAP, so fragmented MP4
+// segments needing an initialization segment cannot be played.
+var segmentTypes = map[string]string{
+	".ts":  "video/mp2t",
+	".aac": "audio/aac",
+}
+
+var segmentFilePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}\.[a-z0-9]+$`)
+
+// MasterPlaylist lists the renditions of a video, lowest bandwidth first, each
+// pointing at its media playlist relative to the master.
+func MasterPlaylist(renditions []entity.Rendition) string {
+	sorted := append([]entity.Rendition{}, renditions...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Bandwidth < sorted[j].Bandwidth })
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, rendition := range sorted {
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d", rendition.Bandwidth)
+		if rendition.Width > 0 && rendition.Height > 0 {
+			fmt.Fprintf(&b, ",RESOLUTION=%dx%d", rendition.Width, rendition.Height)
+		}
+		if rendition.Codecs != "" {
+			fmt.Fprintf(&b, `,CODECS="%s"`, rendition.Codecs)
+		}
+		fmt.Fprintf(&b, "\n%s/index.m3u8\n", rendition.Name)
+	}
+	return b.String()
+}
+
+// MediaPlaylist lists the segments of a complete rendition. Segment URIs are
+// relative to the playlist.
+func MediaPlaylist(rendition entity.Rendition) string {
+	target := 0.0
+	for _, segment := range rendition.Segments {
+		target = math.Max(target, segment.Duration)
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(target)))
+	b.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n")
+	for _, segment := range rendition.Segments {
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s\n", segment.Duration, segment.File)
+	}
+	b.WriteString("#EXT-X-ENDLIST\n")
+	return b.String()
+}
+
+func segmentKey(videoID, rendition, file string) string {
+	return "videos/" + videoID + "/hls/" + rendition + "/" + file
+}
+
+// checkSegments reports segment files with unsafe names or unknown types, and
+// segments that are missing from the blob store.
+func (service *videoService) checkSegments(ctx context.Context, videoID string, rendition entity.Rendition) error {
+	var fields []FieldError
+	for i, segment := range rendition.Segments {
+		field := fmt.Sprintf("segments[%d].file", i)
+		if _, known := segmentTypes[path.Ext(segment.File)]; !known || !segmentFilePattern.MatchString(segment.File) {
+			fields = append(fields, FieldError{Field: field, Rule: "segment_file", Message: field + " must be a .ts or .aac file name"})
+			continue
+		}
+
+		file, _, err := service.blobs.Get(ctx, segmentKey(videoID, rendition.Name, segment.File))
+		if err != nil {
+			if errors.Is(err, ErrBlobNotFound) {
+				fields = append(fields, FieldError{Field: field, Rule: "exists", Message: segment.File + " has not been stored"})
+				continue
+			}
+			return AsError(err)
+		}
+		file.Close()
+	}
+	if len(fields) > 0 {
+		return InvalidFields(fields)
+	}
+	return nil
+}
+
+// PutRendition adds rendition to the video with the given ID and version, or
+// replaces the rendition of the same name. Its segments must already be in
+// the blob store. The other renditions are kept as read, so the video read
+// must be at version too.
+func (service *videoService) PutRendition(id string, version int64, rendition entity.Rendition) (entity.Video, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := service.checkSegments(ctx, id, rendition); err != nil {
+		return entity.Video{}, err
+	}
+
+	existing, err := service.FindByID(id)
+	if err != nil {
+		return entity.Video{}, err
+	}
+	if existing.Version != version {
+		return entity.Video{}, ErrVersionMismatch
+	}
+	renditions := []entity.Rendition{}
+	for _, other := range existing.Renditions {
+		if other.Name != rendition.Name {
+			renditions = append(renditions, other)
+		}
+	}
+	renditions = append(renditions, rendition)
+
+	return service.setServerFields(ctx, id, version, bson.M{"renditions": renditions}, time.Now().UTC().Truncate(time.Millisecond))
+}
+
+// FindRendition returns the named rendition of a video.
+func FindRendition(video entity.Video, name string) (entity.Rendition, error) {
+	for _, rendition := range video.Renditions {
+		if rendition.Name == name {
+			return rendition, nil
+		}
+	}
+	return entity.Rendition{}, ErrRenditionNotFound
+}
+
+// OpenSegment opens a segment listed by a rendition of video, with the
+// content type of the segment in the returned info. The caller closes it.
+func (service *videoService) OpenSegment(video entity.Video, name, file string) (io.ReadSeekCloser, BlobInfo, error) {
+	rendition, err := FindRendition(video, name)
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+
+	listed := false
+	for _, segment := range rendition.Segments {
+		listed = listed || segment.File == file
+	}
+	if !listed {
+		return nil, BlobInfo{}, ErrSegmentNotFound
+	}
+
+	reader, info, err := service.blobs.Get(context.Background(), segmentKey(video.ID, name, file))
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			return nil, BlobInfo{}, ErrSegmentNotFound
+		}
+		return nil, BlobInfo{}, err
+	}
+	info.ContentType = segmentTypes[path.Ext(file)]
+	return reader, info, nil
+}
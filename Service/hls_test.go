@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	entity "videoAPI/Entity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRendition = entity.Rendition{
+	Name:      "720p",
+	Bandwidth: 2800000,
+	Width:     1280,
+	Height:    720,
+	Codecs:    "avc1.4d401f,mp4a.40.2",
+	Segments:  []entity.Segment{{File: "0.ts", Duration: 6}, {File: "1.ts", Duration: 4.5}},
+}
+
+func TestMasterPlaylist(t *testing.T) {
+	low := entity.Rendition{Name: "360p", Bandwidth: 800000}
+
+	assert.Equal(t, "#EXTM3U\n#EXT-X-VERSION:3\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000\n360p/index.m3u8\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=2800000,RESOLUTION=1280x720,CODECS=\"avc1.4d401f,mp4a.40.2\"\n720p/index.m3u8\n",
+		MasterPlaylist([]entity.Rendition{testRendition, low}))
+}
+
+func TestMediaPlaylist(t *testing.T) {
+	assert.Equal(t, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:6\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n"+
+		"#EXTINF:6.000,\n0.ts\n#EXTINF:4.500,\n1.ts\n#EXT-X-ENDLIST\n",
+		MediaPlaylist(testRendition))
+}
+
+func TestCheckSegments(t *testing.T) {
+	blobs, err := NewLocalBlobStore(t.TempDir())
+	require.NoError(t, err)
+	service := &videoService{blobs: blobs}
+	_, err = blobs.Put(context.Background(), segmentKey("v1", "720p", "0.ts"), strings.NewReader("ts"), -1, "")
+	require.NoError(t, err)
+
+	rendition := testRendition
+	rendition.Segments = append(append([]entity.Segment{}, rendition.Segments...), entity.Segment{File: "../2.ts", Duration: 1}, entity.Segment{File: "3.m4s", Duration: 1})
+
+	err = service.checkSegments(context.Background(), "v1", rendition)
+	assert.Equal(t, []FieldError{
+		{Field: "segments[1].file", Rule: "exists", Message: "1.ts has not been stored"},
+		{Field: "segments[2].file", Rule: "segment_file", Message: "segments[2].file must be a .ts or .aac file name"},
+		{Field: "segments[3].file", Rule: "segment_file", Message: "segments[3].file must be a .ts or .aac file name"},
+	}, AsError(err).Fields)
+
+	_, err = blobs.Put(context.Background(), segmentKey("v1", "720p", "1.ts"), strings.NewReader("ts"), -1, "")
+	require.NoError(t, err)
+	assert.NoError(t, service.checkSegments(context.Background(), "v1", testRendition))
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureIndexes creates the indexes the service relies on. The unique
+// indexes are what makes concurrent inserts of the same video ID or user
+// email fail with a conflict rather than silently duplicating.
+func (service *videoService) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := service.videoCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = service.videoCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "title", Value: 1}, {Key: "id", Value: 1}}},
+		{Keys: bson.D{{Key: "created_at", Value: 1}, {Key: "id", Value: 1}}},
+		{Keys: bson.D{{Key: "views", Value: 1}, {Key: "id", Value: 1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+		{Keys: bson.D{{Key: "owner", Value: 1}}},
+		{Keys: bson.D{{Key: "link_status.checked_at", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Videos have their own "language" field holding BCP 47 tags, which Mongo
+	// would otherwise read as the stemming language of each document.
+	_, err = service.videoCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "title", Value: "text"},
+			{Key: "description", Value: "text"},
+			{Key: "tags", Value: "text"},
+		},
+		Options: options.Index().
+			SetName("video_text").
+			SetWeights(bson.M{"title": 10, "tags": 5, "description": 1}).
+			SetDefaultLanguage("english").
+			SetLanguageOverride("text_language"),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = service.videoCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = service.userCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Stores backed by Mongo bring their own indexes
+	for _, store := range []interface{}{service.revisions, service.uploads, service.captions} {
+		if indexed, ok := store.(interface{ EnsureIndexes() error }); ok {
+			if err := indexed.EnsureIndexes(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	entity "videoAPI/Entity"
+)
+
+// DefaultMaxAttempts is how often a job is tried before it is dead-lettered.
+const DefaultMaxAttempts = 5
+
+var (
+	ErrJobNotFound = NotFound("job_not_found", "Job not found")
+	ErrJobRunning  = Conflict("job_running", "Job is running")
+	// ErrJobLost reports the outcome of an attempt that ran past its
+	// visibility timeout, by which time the job was handed to another worker.
+	ErrJobLost = Conflict("job_lost", "Job is no longer reserved by this attempt")
+)
+
+// JobStates lists the states jobs can be listed by.
+var JobStates = []string{entity.JobReady, entity.JobScheduled, entity.JobRunning, entity.JobDead}
+
+// JobQueue holds background jobs until a worker runs them. Jobs are
+// delivered at least once: an attempt that outlives its visibility timeout
+// is given to another worker.
+type JobQueue interface {
+	// Enqueue adds job, to become ready at job.RunAt. It returns false, and
+	// leaves the queue as it was, when a job with the same ID is queued
+	// already.
+	Enqueue(job entity.Job) (bool, error)
+	// EnqueueOnce enqueues job unless a job with its ID was enqueued within
+	// the last ttl, even one that has completed since. Recurring jobs use it
+	// to run once per period however many instances schedule them.
+	EnqueueOnce(job entity.Job, ttl time.Duration) (bool, error)
+	// Reserve takes the next ready job and marks it running until
+	// now+visibility, counting an attempt. Scheduled jobs that are due and
+	// running jobs whose visibility timeout has passed become ready first.
+	// ok is false when no job is ready.
+	Reserve(now time.Time, visibility time.Duration) (job entity.Job, ok bool, err error)
+	// Complete removes a job whose attempt succeeded.
+	Complete(job entity.Job) error
+	// Fail records a failed attempt. The job is scheduled again at retryAt,
+	// or dead-lettered when it has no attempts left.
+	Fail(job entity.Job, reason string, retryAt time.Time) error
+	Get(id string) (entity.Job, error)
+	// List returns up to limit jobs in state: ready and scheduled jobs in the
+	// order they will run, running ones by deadline and dead ones newest
+	// first.
+	List(state string, limit int) ([]entity.Job, error)
+	// Counts returns how many jobs are in each state.
+	Counts() (map[string]int64, error)
+	// Retry makes a dead or scheduled job ready now, giving a dead job all
+	// its attempts again.
+	Retry(id string, now time.Time) (entity.Job, error)
+}
+
+// NewJob creates a job of jobType, ready now, with payload encoded as JSON.
+func NewJob(jobType string, payload interface{}) (entity.Job, error) {
+	id, err := newVideoID()
+	if err != nil {
+		return entity.Job{}, Internal(err)
+	}
+
+	var encoded json.RawMessage
+	if payload != nil {
+		if encoded, err = json.Marshal(payload); err != nil {
+			return entity.Job{}, Internal(err)
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	return entity.Job{
+		ID:          id,
+		Type:        jobType,
+		Payload:     encoded,
+		MaxAttempts: DefaultMaxAttempts,
+		CreatedAt:   now,
+		RunAt:       now,
+	}, nil
+}
+
+type memoryJobQueue struct {
+	mu        sync.Mutex
+	jobs      map[string]entity.Job
+	ready     []string // oldest first
+	deadlines map[string]time.Time
+	once      map[string]time.Time // EnqueueOnce marker expiries
+}
+
+// NewMemoryJobQueue keeps jobs in process memory, for tests and
+// single-instance deployments without Redis. Jobs do not survive a restart.
+func NewMemoryJobQueue() JobQueue {
+	return &memoryJobQueue{jobs: map[string]entity.Job{}, deadlines: map[string]time.Time{}, once: map[string]time.Time{}}
+}
+
+func (queue *memoryJobQueue) Enqueue(job entity.Job) (bool, error) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	return queue.enqueue(job), nil
+}
+
+func (queue *memoryJobQueue) EnqueueOnce(job entity.Job, ttl time.Duration) (bool, error) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	now := time.Now()
+	if expires, ok := queue.once[job.ID]; ok && now.Before(expires) {
+		return false, nil
+	}
+	queue.once[job.ID] = now.Add(ttl)
+	return queue.enqueue(job), nil
+}
+
+func (queue *memoryJobQueue) enqueue(job entity.Job) bool {
+	if _, exists := queue.jobs[job.ID]; exists {
+		return false
+	}
+	job.Attempts = 0
+	job.UpdatedAt = job.CreatedAt
+	job.State = entity.JobScheduled
+	if !job.RunAt.After(time.Now()) {
+		job.State = entity.JobReady
+		queue.ready = append(queue.ready, job.ID)
+	}
+	queue.jobs[job.ID] = job
+	return true
+}
+
+func (queue *memoryJobQueue) Reserve(now time.Time, visibility time.Duration) (entity.Job, bool, error) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	for _, job := range queue.inState(entity.JobScheduled) {
+		if !job.RunAt.After(now) {
+			queue.makeReady(job, now)
+		}
+	}
+	for _, job := range queue.inState(entity.JobRunning) {
+		if !queue.deadlines[job.ID].After(now) {
+			delete(queue.deadlines, job.ID)
+			job.LastError = "visibility timeout expired"
+			if job.Attempts >= job.MaxAttempts {
+				job.State, job.UpdatedAt = entity.JobDead, now
+				queue.jobs[job.ID] = job
+				continue
+			}
+			queue.makeReady(job, now)
+		}
+	}
+
+	if len(queue.ready) == 0 {
+		return entity.Job{}, false, nil
+	}
+	job := queue.jobs[queue.ready[0]]
+	queue.ready = queue.ready[1:]
+
+	job.State, job.UpdatedAt = entity.JobRunning, now
+	job.Attempts++
+	queue.jobs[job.ID] = job
+	queue.deadlines[job.ID] = now.Add(visibility)
+	return job, true, nil
+}
+
+func (queue *memoryJobQueue) makeReady(job entity.Job, now time.Time) {
+	job.State, job.UpdatedAt = entity.JobReady, now
+	queue.jobs[job.ID] = job
+	queue.ready = append(queue.ready, job.ID)
+}
+
+// inState lists the jobs in state, in the order List returns them.
+func (queue *memoryJobQueue) inState(state string) []entity.Job {
+	jobs := []entity.Job{}
+	if state == entity.JobReady {
+		for _, id := range queue.ready {
+			jobs = append(jobs, queue.jobs[id])
+		}
+		return jobs
+	}
+
+	for _, job := range queue.jobs {
+		if job.State == state {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		switch state {
+		case entity.JobScheduled:
+			return jobs[i].RunAt.Before(jobs[j].RunAt)
+		case entity.JobRunning:
+			return queue.deadlines[jobs[i].ID].Before(queue.deadlines[jobs[j].ID])
+		default:
+			return jobs[i].UpdatedAt.After(jobs[j].UpdatedAt)
+		}
+	})
+	return jobs
+}
+
+// reserved returns the stored job if attempt still holds it.
+func (queue *memoryJobQueue) reserved(attempt entity.Job) (entity.Job, error) {
+	job, ok := queue.jobs[attempt.ID]
+	if !ok || job.State != entity.JobRunning || job.Attempts != attempt.Attempts {
+		return entity.Job{}, ErrJobLost
+	}
+	return job, nil
+}
+
+func (queue *memoryJobQueue) Complete(attempt entity.Job) error {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	if _, err := queue.reserved(attempt); err != nil {
+		return err
+	}
+	delete(queue.jobs, attempt.ID)
+	delete(queue.deadlines, attempt.ID)
+	return nil
+}
+
+func (queue *memoryJobQueue) Fail(attempt entity.Job, reason string, retryAt time.Time) error {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	job, err := queue.reserved(attempt)
+	if err != nil {
+		return err
+	}
+	delete(queue.deadlines, job.ID)
+
+	job.LastError, job.UpdatedAt = reason, time.Now().UTC()
+	if job.Attempts >= job.MaxAttempts {
+		job.State = entity.JobDead
+	} else {
+		job.State, job.RunAt = entity.JobScheduled, retryAt
+	}
+	queue.jobs[job.ID] = job
+	return nil
+}
+
+func (queue *memoryJobQueue) Get(id string) (entity.Job, error) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	job, ok := queue.jobs[id]
+	if !ok {
+		return entity.Job{}, ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (queue *memoryJobQueue) List(state string, limit int) ([]entity.Job, error) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	jobs := queue.inState(state)
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+func (queue *memoryJobQueue) Counts() (map[string]int64, error) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	counts := map[string]int64{}
+	for _, state := range JobStates {
+		counts[state] = 0
+	}
+	for _, job := range queue.jobs {
+		counts[job.State]++
+	}
+	return counts, nil
+}
+
+func (queue *memoryJobQueue) Retry(id string, now time.Time) (entity.Job, error) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	job, ok := queue.jobs[id]
+	if !ok {
+		return entity.Job{}, ErrJobNotFound
+	}
+	switch job.State {
+	case entity.JobRunning:
+		return entity.Job{}, ErrJobRunning
+	case entity.JobReady:
+		return job, nil
+	case entity.JobDead:
+		job.Attempts = 0
+	}
+	job.RunAt = now
+	queue.makeReady(job, now)
+	return queue.jobs[id], nil
+}
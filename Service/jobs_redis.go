@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	entity "videoAPI/Entity"
+)
+
+// The Redis queue keeps each job in a hash under jobs:job:<id> and its ID in
+// the structure of its state: the jobs:ready list, which workers take from
+// the right, or the jobs:scheduled, jobs:running and jobs:dead sorted sets,
+// scored by run time, visibility deadline and time of death. Every change of
+// state is a script, so it happens atomically. Times are Unix milliseconds.
+// EnqueueOnce leaves a jobs:once:<id> marker that expires after its ttl.
+const (
+	jobKeyPrefix     = "jobs:job:"
+	jobOncePrefix    = "jobs:once:"
+	jobReadyKey      = "jobs:ready"
+	jobScheduledKey  = "jobs:scheduled"
+	jobRunningKey    = "jobs:running"
+	jobDeadKey       = "jobs:dead"
+	jobPromoteBatch  = 100
+	jobLostReturn    = 0
+	jobMissingReturn = -1
+)
+
+var jobStateKeys = map[string]string{
+	entity.JobReady:     jobReadyKey,
+	entity.JobScheduled: jobScheduledKey,
+	entity.JobRunning:   jobRunningKey,
+	entity.JobDead:      jobDeadKey,
+}
+
+var enqueueScript = redis.NewScript(`
+local key = ARGV[1] .. ARGV[2]
+if ARGV[9] ~= '0' and not redis.call('SET', KEYS[3], 1, 'NX', 'PX', ARGV[9]) then return 0 end
+if redis.call('EXISTS', key) == 1 then return 0 end
+redis.call('HSET', key, 'id', ARGV[2], 'type', ARGV[3], 'payload', ARGV[4], 'attempts', 0,
+  'max_attempts', ARGV[5], 'created_at', ARGV[6], 'run_at', ARGV[7], 'updated_at', ARGV[6])
+if tonumber(ARGV[7]) > tonumber(ARGV[8]) then
+  redis.call('HSET', key, 'state', 'scheduled')
+  redis.call('ZADD', KEYS[2], ARGV[7], ARGV[2])
+else
+  redis.call('HSET', key, 'state', 'ready')
+  redis.call('LPUSH', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+var reserveScript = redis.NewScript(`
+local now = ARGV[2]
+for _, id in ipairs(redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', now, 'LIMIT', 0, ARGV[4])) do
+  redis.call('ZREM', KEYS[2], id)
+  redis.call('HSET', ARGV[1] .. id, 'state', 'ready', 'updated_at', now)
+  redis.call('LPUSH', KEYS[1], id)
+end
+for _, id in ipairs(redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', now, 'LIMIT', 0, ARGV[4])) do
+  local key = ARGV[1] .. id
+  redis.call('ZREM', KEYS[3], id)
+  redis.call('HSET', key, 'last_error', 'visibility timeout expired', 'updated_at', now)
+  if tonumber(redis.call('HGET', key, 'attempts')) >= tonumber(redis.call('HGET', key, 'max_attempts')) then
+    redis.call('HSET', key, 'state', 'dead')
+    redis.call('ZADD', KEYS[4], now, id)
+  else
+    redis.call('HSET', key, 'state', 'ready')
+    redis.call('LPUSH', KEYS[1], id)
+  end
+end
+while true do
+  local id = redis.call('RPOP', KEYS[1])
+  if not id then return false end
+  local key = ARGV[1] .. id
+  if redis.call('EXISTS', key) == 1 then
+    redis.call('ZADD', KEYS[3], ARGV[3], id)
+    redis.call('HINCRBY', key, 'attempts', 1)
+    redis.call('HSET', key, 'state', 'running', 'updated_at', now)
+    return id
+  end
+end
+`)
+
+// reservedGuard checks that ARGV[2] is still running at attempt ARGV[3].
+const reservedGuard = `
+local key = ARGV[1] .. ARGV[2]
+if redis.call('EXISTS', key) == 0 then return -1 end
+if redis.call('HGET', key, 'state') ~= 'running' or redis.call('HGET', key, 'attempts') ~= ARGV[3] then return 0 end
+`
+
+var completeScript = redis.NewScript(reservedGuard + `
+redis.call('ZREM', KEYS[1], ARGV[2])
+redis.call('DEL', key)
+return 1
+`)
+
+var failScript = redis.NewScript(reservedGuard + `
+redis.call('ZREM', KEYS[1], ARGV[2])
+redis.call('HSET', key, 'last_error', ARGV[4], 'updated_at', ARGV[5])
+if tonumber(ARGV[3]) >= tonumber(redis.call('HGET', key, 'max_attempts')) then
+  redis.call('HSET', key, 'state', 'dead')
+  redis.call('ZADD', KEYS[3], ARGV[5], ARGV[2])
+else
+  redis.call('HSET', key, 'state', 'scheduled', 'run_at', ARGV[6])
+  redis.call('ZADD', KEYS[2], ARGV[6], ARGV[2])
+end
+return 1
+`)
+
+var retryScript = redis.NewScript(`
+local key = ARGV[1] .. ARGV[2]
+local state = redis.call('HGET', key, 'state')
+if not state then return -1 end
+if state == 'running' then return 0 end
+if state == 'ready' then return 1 end
+if state == 'dead' then
+  redis.call('ZREM', KEYS[3], ARGV[2])
+  redis.call('HSET', key, 'attempts', 0)
+else
+  redis.call('ZREM', KEYS[2], ARGV[2])
+end
+redis.call('HSET', key, 'state', 'ready', 'run_at', ARGV[3], 'updated_at', ARGV[3])
+redis.call('LPUSH', KEYS[1], ARGV[2])
+return 1
+`)
+
+type redisJobQueue struct {
+	client *redis.Client
+}
+
+// NewRedisJobQueue keeps jobs in Redis, so they survive restarts and can be
+// run by workers in other processes.
+func NewRedisJobQueue(client *redis.Client) JobQueue {
+	return &redisJobQueue{client: client}
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+func (queue *redisJobQueue) Enqueue(job entity.Job) (bool, error) {
+	return queue.enqueue(job, 0)
+}
+
+func (queue *redisJobQueue) EnqueueOnce(job entity.Job, ttl time.Duration) (bool, error) {
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+	return queue.enqueue(job, ttl)
+}
+
+// enqueue runs enqueueScript, first setting the once marker of job when ttl
+// is not 0.
+func (queue *redisJobQueue) enqueue(job entity.Job, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	added, err := enqueueScript.Run(ctx, queue.client, []string{jobReadyKey, jobScheduledKey, jobOncePrefix + job.ID},
+		jobKeyPrefix, job.ID, job.Type, string(job.Payload), job.MaxAttempts,
+		millis(job.CreatedAt), millis(job.RunAt), millis(time.Now()), ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return added == 1, nil
+}
+
+func (queue *redisJobQueue) Reserve(now time.Time, visibility time.Duration) (entity.Job, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id, err := reserveScript.Run(ctx, queue.client, []string{jobReadyKey, jobScheduledKey, jobRunningKey, jobDeadKey},
+		jobKeyPrefix, millis(now), millis(now.Add(visibility)), jobPromoteBatch).Text()
+	if err == redis.Nil {
+		return entity.Job{}, false, nil
+	}
+	if err != nil {
+		return entity.Job{}, false, err
+	}
+
+	job, err := queue.Get(id)
+	if err != nil {
+		return entity.Job{}, false, err
+	}
+	return job, true, nil
+}
+
+// guarded runs a script starting with reservedGuard, mapping its results.
+func (queue *redisJobQueue) guarded(script *redis.Script, attempt entity.Job, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	args = append([]interface{}{jobKeyPrefix, attempt.ID, attempt.Attempts}, args...)
+	result, err := script.Run(ctx, queue.client, []string{jobRunningKey, jobScheduledKey, jobDeadKey}, args...).Int()
+	switch {
+	case err != nil:
+		return err
+	case result == jobLostReturn, result == jobMissingReturn:
+		return ErrJobLost
+	}
+	return nil
+}
+
+func (queue *redisJobQueue) Complete(attempt entity.Job) error {
+	return queue.guarded(completeScript, attempt)
+}
+
+func (queue *redisJobQueue) Fail(attempt entity.Job, reason string, retryAt time.Time) error {
+	return queue.guarded(failScript, attempt, reason, millis(time.Now()), millis(retryAt))
+}
+
+func (queue *redisJobQueue) Get(id string) (entity.Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fields, err := queue.client.HGetAll(ctx, jobKeyPrefix+id).Result()
+	if err != nil {
+		return entity.Job{}, err
+	}
+	return jobFromHash(fields)
+}
+
+func jobFromHash(fields map[string]string) (entity.Job, error) {
+	if len(fields) == 0 {
+		return entity.Job{}, ErrJobNotFound
+	}
+
+	number := func(name string) int64 {
+		value, _ := strconv.ParseInt(fields[name], 10, 64)
+		return value
+	}
+	timestamp := func(name string) time.Time {
+		return time.UnixMilli(number(name)).UTC()
+	}
+
+	job := entity.Job{
+		ID:          fields["id"],
+		Type:        fields["type"],
+		State:       fields["state"],
+		Attempts:    int(number("attempts")),
+		MaxAttempts: int(number("max_attempts")),
+		LastError:   fields["last_error"],
+		CreatedAt:   timestamp("created_at"),
+		RunAt:       timestamp("run_at"),
+		UpdatedAt:   timestamp("updated_at"),
+	}
+	if payload := fields["payload"]; payload != "" {
+		job.Payload = []byte(payload)
+	}
+	return job, nil
+}
+
+func (queue *redisJobQueue) List(state string, limit int) ([]entity.Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var ids []string
+	var err error
+	switch state {
+	case entity.JobReady:
+		// Workers take from the right, so the next to run is last
+		ids, err = queue.client.LRange(ctx, jobReadyKey, int64(-limit), -1).Result()
+		for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+			ids[i], ids[j] = ids[j], ids[i]
+		}
+	case entity.JobDead:
+		ids, err = queue.client.ZRevRange(ctx, jobDeadKey, 0, int64(limit-1)).Result()
+	default:
+		ids, err = queue.client.ZRange(ctx, jobStateKeys[state], 0, int64(limit-1)).Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := queue.client.Pipeline()
+	commands := make([]*redis.StringStringMapCmd, len(ids))
+	for i, id := range ids {
+		commands[i] = pipe.HGetAll(ctx, jobKeyPrefix+id)
+	}
+	if len(ids) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	jobs := []entity.Job{}
+	for _, command := range commands {
+		job, err := jobFromHash(command.Val())
+		if errors.Is(err, ErrJobNotFound) {
+			continue // completed since it was listed
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+func (queue *redisJobQueue) Counts() (map[string]int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipe := queue.client.Pipeline()
+	ready := pipe.LLen(ctx, jobReadyKey)
+	scheduled := pipe.ZCard(ctx, jobScheduledKey)
+	running := pipe.ZCard(ctx, jobRunningKey)
+	dead := pipe.ZCard(ctx, jobDeadKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	return map[string]int64{
+		entity.JobReady:     ready.Val(),
+		entity.JobScheduled: scheduled.Val(),
+		entity.JobRunning:   running.Val(),
+		entity.JobDead:      dead.Val(),
+	}, nil
+}
+
+func (queue *redisJobQueue) Retry(id string, now time.Time) (entity.Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := retryScript.Run(ctx, queue.client, []string{jobReadyKey, jobScheduledKey, jobDeadKey},
+		jobKeyPrefix, id, millis(now)).Int()
+	switch {
+	case err != nil:
+		return entity.Job{}, err
+	case result == jobMissingReturn:
+		return entity.Job{}, ErrJobNotFound
+	case result == jobLostReturn:
+		return entity.Job{}, ErrJobRunning
+	}
+	return queue.Get(id)
+}
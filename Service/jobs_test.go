@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	entity "videoAPI/Entity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func enqueueTestJob(t *testing.T, queue JobQueue, jobType string) entity.Job {
+	job, err := NewJob(jobType, map[string]string{"video": "v1"})
+	require.NoError(t, err)
+	added, err := queue.Enqueue(job)
+	require.NoError(t, err)
+	require.True(t, added)
+	return job
+}
+
+func TestJobQueueRunsJobsInOrder(t *testing.T) {
+	queue := NewMemoryJobQueue()
+	first := enqueueTestJob(t, queue, "a")
+	second := enqueueTestJob(t, queue, "b")
+
+	added, err := queue.Enqueue(first)
+	require.NoError(t, err)
+	assert.False(t, added, "same ID")
+
+	now := time.Now()
+	job, ok, err := queue.Reserve(now, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, first.ID, job.ID)
+	assert.Equal(t, entity.JobRunning, job.State)
+	assert.Equal(t, 1, job.Attempts)
+	assert.JSONEq(t, `{"video":"v1"}`, string(job.Payload))
+
+	require.NoError(t, queue.Complete(job))
+	_, err = queue.Get(first.ID)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	job, _, _ = queue.Reserve(now, time.Minute)
+	assert.Equal(t, second.ID, job.ID)
+	_, ok, _ = queue.Reserve(now, time.Minute)
+	assert.False(t, ok)
+}
+
+func TestJobQueueRetriesThenDeadLetters(t *testing.T) {
+	queue := NewMemoryJobQueue()
+	enqueued := enqueueTestJob(t, queue, "a")
+	now := time.Now()
+
+	for attempt := 1; attempt <= DefaultMaxAttempts; attempt++ {
+		job, ok, err := queue.Reserve(now, time.Minute)
+		require.NoError(t, err)
+		require.True(t, ok, "attempt %d", attempt)
+		assert.Equal(t, attempt, job.Attempts)
+
+		retryAt := now.Add(time.Minute)
+		require.NoError(t, queue.Fail(job, "boom", retryAt))
+		if attempt < DefaultMaxAttempts {
+			_, ok, _ = queue.Reserve(now, time.Minute)
+			assert.False(t, ok, "backing off")
+			now = retryAt
+		}
+	}
+
+	job, err := queue.Get(enqueued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.JobDead, job.State)
+	assert.Equal(t, "boom", job.LastError)
+	_, ok, _ := queue.Reserve(now.Add(time.Hour), time.Minute)
+	assert.False(t, ok)
+
+	dead, _ := queue.List(entity.JobDead, 10)
+	require.Len(t, dead, 1)
+	counts, _ := queue.Counts()
+	assert.Equal(t, map[string]int64{"ready": 0, "scheduled": 0, "running": 0, "dead": 1}, counts)
+
+	job, err = queue.Retry(enqueued.ID, now)
+	require.NoError(t, err)
+	assert.Equal(t, entity.JobReady, job.State)
+	assert.Equal(t, 0, job.Attempts)
+	_, ok, _ = queue.Reserve(now, time.Minute)
+	assert.True(t, ok)
+
+	_, err = queue.Retry(enqueued.ID, now)
+	assert.ErrorIs(t, err, ErrJobRunning)
+	_, err = queue.Retry("missing", now)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestJobQueueVisibilityTimeout(t *testing.T) {
+	queue := NewMemoryJobQueue()
+	enqueueTestJob(t, queue, "a")
+	now := time.Now()
+
+	first, _, _ := queue.Reserve(now, time.Minute)
+	_, ok, _ := queue.Reserve(now.Add(30*time.Second), time.Minute)
+	assert.False(t, ok, "still reserved")
+
+	second, ok, _ := queue.Reserve(now.Add(2*time.Minute), time.Minute)
+	require.True(t, ok, "redelivered after the timeout")
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, 2, second.Attempts)
+	assert.Equal(t, "visibility timeout expired", second.LastError)
+
+	assert.ErrorIs(t, queue.Complete(first), ErrJobLost, "the late attempt")
+	assert.NoError(t, queue.Complete(second))
+}
+
+func TestJobQueueScheduledJobs(t *testing.T) {
+	queue := NewMemoryJobQueue()
+	job, err := NewJob("a", nil)
+	require.NoError(t, err)
+	job.RunAt = job.CreatedAt.Add(time.Hour)
+	_, err = queue.Enqueue(job)
+	require.NoError(t, err)
+
+	_, ok, _ := queue.Reserve(time.Now(), time.Minute)
+	assert.False(t, ok)
+	scheduled, _ := queue.List(entity.JobScheduled, 10)
+	require.Len(t, scheduled, 1)
+
+	_, ok, _ = queue.Reserve(job.RunAt, time.Minute)
+	assert.True(t, ok)
+}
+
+func TestJobQueueEnqueueOnce(t *testing.T) {
+	queue := NewMemoryJobQueue()
+	job, err := NewJob("purge", nil)
+	require.NoError(t, err)
+	job.ID = "purge:1700000000"
+
+	added, err := queue.EnqueueOnce(job, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	reserved, ok, err := queue.Reserve(time.Now(), time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, queue.Complete(reserved))
+
+	added, err = queue.EnqueueOnce(job, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, added, "completed within the ttl")
+
+	job.ID = "purge:1700003600"
+	added, err = queue.EnqueueOnce(job, time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, added)
+	reserved, _, _ = queue.Reserve(time.Now(), time.Minute)
+	require.NoError(t, queue.Complete(reserved))
+	time.Sleep(2 * time.Millisecond)
+	added, err = queue.EnqueueOnce(job, time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, added, "marker expired")
+}
+
+func TestWorkerPool(t *testing.T) {
+	queue := NewMemoryJobQueue()
+	pool := NewWorkerPool(queue)
+	pool.BaseBackoff, pool.MaxBackoff = time.Hour, time.Hour
+
+	var ran []string
+	pool.Handle("ok", func(ctx context.Context, job entity.Job) error {
+		ran = append(ran, job.ID)
+		return nil
+	})
+	pool.Handle("fails", func(ctx context.Context, job entity.Job) error { return errors.New("boom") })
+	pool.Handle("panics", func(ctx context.Context, job entity.Job) error { panic("oops") })
+
+	succeeds := enqueueTestJob(t, queue, "ok")
+	fails := enqueueTestJob(t, queue, "fails")
+	panics := enqueueTestJob(t, queue, "panics")
+	unknown := enqueueTestJob(t, queue, "unknown")
+
+	for pool.RunOne() {
+	}
+
+	assert.Equal(t, []string{succeeds.ID}, ran)
+	_, err := queue.Get(succeeds.ID)
+	assert.ErrorIs(t, err, ErrJobNotFound, "removed once done")
+
+	for id, reason := range map[string]string{fails.ID: "boom", panics.ID: "panic: oops", unknown.ID: "no handler for job type unknown"} {
+		job, err := queue.Get(id)
+		require.NoError(t, err)
+		assert.Equal(t, entity.JobScheduled, job.State)
+		assert.Equal(t, reason, job.LastError)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), job.RunAt, 13*time.Minute)
+	}
+}
+
+func TestWorkerPoolBackoff(t *testing.T) {
+	pool := NewWorkerPool(NewMemoryJobQueue())
+	pool.BaseBackoff, pool.MaxBackoff = 10*time.Second, time.Minute
+
+	for attempts, want := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 3: 40 * time.Second, 4: time.Minute, 30: time.Minute} {
+		delay := pool.backoff(attempts)
+		assert.LessOrEqual(t, delay, want, "attempt %d", attempts)
+		assert.GreaterOrEqual(t, delay, want*4/5, "attempt %d", attempts)
+	}
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	entity "videoAPI/Entity"
+)
+
+// Defaults of a LinkChecker.
+const (
+	DefaultLinkTimeout     = 10 * time.Second
+	DefaultLinkConcurrency = 8
+	maxLinkRedirects       = 10
+	maxLinkBody            = 64 << 10
+)
+
+var errPrivateAddress = errors.New("address is not public")
+
+// LinkChecker probes video URLs: a HEAD request first and, when the server
+// refuses or fails it, a GET for the first byte. Redirects are followed up to
+// maxLinkRedirects. Unless built to allow them, it will not connect to
+// loopback, private or link-local addresses, so catalog URLs cannot be used
+// to reach the internal network. It never goes through a proxy, which would
+// connect to the target on its behalf, out of reach of that check.
+type LinkChecker struct {
+	Client      *http.Client
+	Concurrency int
+}
+
+// NewLinkChecker returns a checker giving each request up to timeout.
+func NewLinkChecker(timeout time.Duration, concurrency int, allowPrivate bool) *LinkChecker {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = publicOnly
+	}
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConnsPerHost:   2,
+		IdleConnTimeout:       time.Minute,
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) >= maxLinkRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxLinkRedirects)
+			}
+			return nil
+		},
+	}
+	return &LinkChecker{Client: client, Concurrency: concurrency}
+}
+
+// publicOnly refuses connections to addresses that are not on the public
+// internet. It runs after name resolution, so names pointing at internal
+// addresses are refused too.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// Check probes one URL. Network errors and responses of 400 or above mark
+// the link dead. If ctx ends before the check does, the zero LinkStatus is
+// returned, as the link was not found dead.
+func (checker *LinkChecker) Check(ctx context.Context, rawURL string) entity.LinkStatus {
+	status := entity.LinkStatus{State: entity.LinkDead, URL: rawURL, CheckedAt: time.Now().UTC()}
+
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		status.Error = "unsupported URL"
+		return status
+	}
+
+	response, err := checker.request(ctx, http.MethodHead, rawURL)
+	if (err != nil && !isTimeout(err)) || (err == nil && response.StatusCode >= 400) {
+		// Some servers do not implement HEAD, or answer it differently
+		response, err = checker.request(ctx, http.MethodGet, rawURL)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return entity.LinkStatus{}
+		}
+		status.Error = linkError(err)
+		return status
+	}
+
+	status.StatusCode = response.StatusCode
+	if final := response.Request.URL.String(); final != rawURL {
+		status.FinalURL = final
+	}
+	if response.StatusCode < 400 {
+		status.State = entity.LinkOK
+	}
+	return status
+}
+
+func (checker *LinkChecker) request(ctx context.Context, method, rawURL string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if method == http.MethodGet {
+		request.Header.Set("Range", "bytes=0-0")
+	}
+	response, err := checker.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(io.Discard, io.LimitReader(response.Body, maxLinkBody))
+	response.Body.Close()
+	return response, nil
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// linkError describes why a request failed, without the method and URL the
+// client wraps around it.
+func linkError(err error) string {
+	if isTimeout(err) {
+		return "timeout"
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	if errors.Is(err, errPrivateAddress) {
+		return errPrivateAddress.Error()
+	}
+	return err.Error()
+}
+
+// CheckAll probes the URLs, at most Concurrency at a time, returning their
+// statuses in the same order. URLs not checked by the time ctx ends have the
+// zero LinkStatus.
+func (checker *LinkChecker) CheckAll(ctx context.Context, urls []string) []entity.LinkStatus {
+	concurrency := checker.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	statuses := make([]entity.LinkStatus, len(urls))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, rawURL := range urls {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, rawURL string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			statuses[i] = checker.Check(ctx, rawURL)
+		}(i, rawURL)
+	}
+	wg.Wait()
+	return statuses
+}
+
+// CheckLinks probes the URLs of up to limit videos that have not been
+// checked, were last checked more than maxAge ago or have had their URL
+// changed since, least recently checked first. Like views, link statuses are
+// not a revision of the video and leave its version alone. When ctx ends
+// first, the links checked so far are stored and the rest left to the next
+// run.
+func (service *videoService) CheckLinks(ctx context.Context, checker *LinkChecker, maxAge time.Duration, limit int) (int, int, error) {
+	findCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := live(bson.M{
+		"url": bson.M{"$ne": ""},
+		"$or": bson.A{
+			bson.M{"link_status": nil},
+			bson.M{"link_status.checked_at": bson.M{"$lt": time.Now().Add(-maxAge)}},
+			bson.M{"$expr": bson.M{"$ne": bson.A{"$link_status.url", "$url"}}},
+		},
+	})
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "link_status.checked_at", Value: 1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"id": 1, "url": 1})
+	cursor, err := service.videoCollection.Find(findCtx, filter, findOptions)
+	if err != nil {
+		return 0, 0, Internal(err)
+	}
+	var videos []entity.Video
+	if err := cursor.All(findCtx, &videos); err != nil {
+		return 0, 0, Internal(err)
+	}
+
+	urls := make([]string, len(videos))
+	for i, video := range videos {
+		urls[i] = video.URL
+	}
+	statuses := checker.CheckAll(ctx, urls)
+
+	checked, dead := 0, 0
+	for i, status := range statuses {
+		if status.CheckedAt.IsZero() {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		// The URL may have been edited while it was being checked
+		filter := live(bson.M{"id": videos[i].ID, "url": status.URL})
+		_, err := service.videoCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"link_status": status}})
+		if err == nil {
+			service.uncacheVideo(ctx, videos[i].ID)
+		}
+		cancel()
+		if err != nil {
+			return checked, dead, Internal(err)
+		}
+		checked++
+		if status.State == entity.LinkDead {
+			dead++
+		}
+	}
+	return checked, dead, nil
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	entity "videoAPI/Entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newLinkServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheckLink(t *testing.T) {
+	server := newLinkServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/gone":
+			w.WriteHeader(http.StatusNotFound)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			assert.Equal(t, "bytes=0-0", r.Header.Get("Range"))
+			w.WriteHeader(http.StatusPartialContent)
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		}
+	})
+	checker := NewLinkChecker(time.Second, 2, true)
+	ctx := context.Background()
+
+	status := checker.Check(ctx, server.URL+"/ok")
+	assert.Equal(t, entity.LinkOK, status.State)
+	assert.Equal(t, http.StatusOK, status.StatusCode)
+	assert.Equal(t, server.URL+"/ok", status.URL)
+	assert.Empty(t, status.FinalURL)
+	assert.False(t, status.CheckedAt.IsZero())
+
+	status = checker.Check(ctx, server.URL+"/gone")
+	assert.Equal(t, entity.LinkDead, status.State)
+	assert.Equal(t, http.StatusNotFound, status.StatusCode)
+
+	status = checker.Check(ctx, server.URL+"/no-head")
+	assert.Equal(t, entity.LinkOK, status.State)
+	assert.Equal(t, http.StatusPartialContent, status.StatusCode)
+
+	status = checker.Check(ctx, server.URL+"/moved")
+	assert.Equal(t, entity.LinkOK, status.State)
+	assert.Equal(t, server.URL+"/ok", status.FinalURL)
+
+	status = checker.Check(ctx, server.URL+"/loop")
+	assert.Equal(t, entity.LinkDead, status.State)
+	assert.Equal(t, "stopped after 10 redirects", status.Error)
+
+	status = checker.Check(ctx, "ftp://example.com/video.mp4")
+	assert.Equal(t, entity.LinkDead, status.State)
+	assert.Equal(t, "unsupported URL", status.Error)
+}
+
+func TestCheckLinkTimeout(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	server := newLinkServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+	})
+	defer close(release)
+
+	status := NewLinkChecker(50*time.Millisecond, 1, true).Check(context.Background(), server.URL)
+	assert.Equal(t, entity.LinkDead, status.State)
+	assert.Equal(t, "timeout", status.Error)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "a HEAD that timed out is not retried as a GET")
+}
+
+func TestCheckLinkRefusesPrivateAddresses(t *testing.T) {
+	server := newLinkServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("private address was requested")
+	})
+
+	checker := NewLinkChecker(time.Second, 1, false)
+	status := checker.Check(context.Background(), server.URL)
+	assert.Equal(t, entity.LinkDead, status.State)
+	assert.Equal(t, "address is not public", status.Error)
+	assert.Nil(t, checker.Client.Transport.(*http.Transport).Proxy, "a proxy would dial private addresses for it")
+}
+
+func TestCheckAllCapsConcurrency(t *testing.T) {
+	var running, peak int32
+	server := newLinkServer(t, func(w http.ResponseWriter, r *http.Request) {
+		now := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			seen := atomic.LoadInt32(&peak)
+			if now <= seen || atomic.CompareAndSwapInt32(&peak, seen, now) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		if r.URL.Path == "/3" {
+			w.WriteHeader(http.StatusGone)
+		}
+	})
+
+	urls := []string{server.URL + "/0", server.URL + "/1", server.URL + "/2", server.URL + "/3", server.URL + "/4", server.URL + "/5"}
+	statuses := NewLinkChecker(time.Second, 2, true).CheckAll(context.Background(), urls)
+
+	for i, status := range statuses {
+		assert.Equal(t, urls[i], status.URL)
+		if i == 3 {
+			assert.Equal(t, entity.LinkDead, status.State)
+		} else {
+			assert.Equal(t, entity.LinkOK, status.State)
+		}
+	}
+	assert.LessOrEqual(t, atomic.LoadInt32(&peak), int32(2))
+}
+
+func TestCheckAllStopsWithContext(t *testing.T) {
+	var requests int32
+	server := newLinkServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	urls := []string{server.URL + "/0", server.URL + "/1", server.URL + "/2"}
+	statuses := NewLinkChecker(5*time.Second, 1, true).CheckAll(ctx, urls)
+
+	assert.Equal(t, make([]entity.LinkStatus, 3), statuses, "links cut off are not marked dead")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestFilterMatchesLinkState(t *testing.T) {
+	dead := entity.Video{LinkStatus: &entity.LinkStatus{State: entity.LinkDead}}
+	filter := VideoFilter{LinkState: entity.LinkDead}
+
+	assert.True(t, filter.Matches(dead))
+	assert.False(t, filter.Matches(entity.Video{}))
+	assert.False(t, VideoFilter{LinkState: entity.LinkOK}.Matches(dead))
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	entity "videoAPI/Entity"
+)
+
+// MaxMediaBytes bounds the size of an uploaded video file. It is set once
+// at startup.
+var MaxMediaBytes int64 = 2 << 30
+
+// sniffBytes is how much of an upload is read to detect its type.
+const sniffBytes = 3072
+
+var (
+	ErrMediaTooLarge = TooLarge("media_too_large", "Video file is larger than the upload limit")
+	ErrMediaNotFound = NotFound("media_not_found", "Video has no uploaded file")
+)
+
+// limitedReader fails with err, rather than stopping quietly, once more than
+// remaining bytes have been read.
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+	err       error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, l.err
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.reader.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, l.err
+	}
+	return n, err
+}
+
+// sniffVideo reads the start of body and checks that it is a video file,
+// returning its type and a reader over the whole of body.
+func sniffVideo(body io.Reader) (*mimetype.MIME, io.Reader, error) {
+	head := make([]byte, sniffBytes)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, nil, err
+	}
+	if n == 0 {
+		return nil, nil, Validation("empty_media", "Video file is empty")
+	}
+	head = head[:n]
+
+	detected := mimetype.Detect(head)
+	if !strings.HasPrefix(detected.String(), "video/") {
+		return nil, nil, Unsupported("unsupported_media", "Uploaded file is "+detected.String()+", not a video")
+	}
+	return detected, io.MultiReader(bytes.NewReader(head), body), nil
+}
+
+// UploadMedia stores body as the file of the video with the given ID and
+// version and points the video's URL at url. Each upload gets a new key, so
+// an upload that loses a version race never overwrites the winner's file.
+func (service *videoService) UploadMedia(id string, version int64, body io.Reader, url, actor string) (entity.Video, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	media, probe, err := service.storeMedia(ctx, id, body)
+	if err != nil {
+		return entity.Video{}, err
+	}
+
+	video, err := service.attachMedia(ctx, id, version, media, probe, url, actor)
+	if err != nil {
+		service.deleteBlob(ctx, media.Key)
+		return entity.Video{}, err
+	}
+	return video, nil
+}
+
+// storeMedia checks that body is a video file and stores it under a new key.
+// MP4 files are probed once stored, since their metadata may come last, and
+// rejected if their boxes cannot be read; the probe is nil for other types.
+func (service *videoService) storeMedia(ctx context.Context, id string, body io.Reader) (entity.Media, *mp4Probe, error) {
+	detected, body, err := sniffVideo(&limitedReader{reader: body, remaining: MaxMediaBytes, err: ErrMediaTooLarge})
+	if err != nil {
+		return entity.Media{}, nil, err
+	}
+
+	upload, err := newVideoID()
+	if err != nil {
+		return entity.Media{}, nil, Internal(err)
+	}
+	key := "videos/" + id + "/media/" + upload
+
+	blob, err := service.blobs.Put(ctx, key, body, -1, detected.String())
+	if err != nil {
+		if errors.Is(err, ErrMediaTooLarge) {
+			return entity.Media{}, nil, ErrMediaTooLarge
+		}
+		return entity.Media{}, nil, AsError(err)
+	}
+
+	var probe *mp4Probe
+	if isoMediaTypes[detected.String()] {
+		if probe, err = service.probeMedia(ctx, key, blob.Size); err != nil {
+			service.deleteBlob(ctx, key)
+			return entity.Media{}, nil, err
+		}
+	}
+
+	media := entity.Media{Key: key, ContentType: detected.String(), Size: blob.Size, UploadedAt: time.Now().UTC().Truncate(time.Millisecond)}
+	return media, probe, nil
+}
+
+func (service *videoService) probeMedia(ctx context.Context, key string, size int64) (*mp4Probe, error) {
+	file, _, err := service.blobs.Get(ctx, key)
+	if err != nil {
+		return nil, AsError(err)
+	}
+	defer file.Close()
+
+	probe, err := probeMP4(file, size)
+	if err != nil {
+		return nil, AsError(err)
+	}
+	return &probe, nil
+}
+
+// attachMedia records media, and what probe read from it, on the video and
+// removes the file it replaces.
+func (service *videoService) attachMedia(ctx context.Context, id string, version int64, media entity.Media, probe *mp4Probe, url, actor string) (entity.Video, error) {
+	var info entity.MediaInfo
+	set := bson.M{"media": media, "url": url, "updated_at": media.UploadedAt}
+	if probe != nil {
+		info = probe.Info
+		set["duration"] = probe.Duration
+	}
+	set["width"], set["height"] = info.Width, info.Height
+	set["video_codec"], set["audio_codec"] = info.VideoCodec, info.AudioCodec
+	set["recorded_at"] = info.RecordedAt
+
+	filter := live(bson.M{"id": id, "version": version})
+	update := bson.M{
+		"$set": set,
+		"$inc": bson.M{"version": 1},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	var previous entity.Video
+	if err := service.videoCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entity.Video{}, service.missOrMismatch(ctx, id)
+		}
+		return entity.Video{}, Internal(err)
+	}
+
+	updated := previous
+	updated.Media = &media
+	updated.MediaInfo = info
+	if probe != nil {
+		updated.Duration = probe.Duration
+	}
+	updated.URL = url
+	updated.UpdatedAt = media.UploadedAt
+	updated.Version = previous.Version + 1
+
+	service.recordRevision(previous, updated, actor)
+	service.cacheVideo(ctx, updated)
+	if previous.Media != nil {
+		service.deleteBlob(ctx, previous.Media.Key)
+	}
+
+	return updated, nil
+}
+
+// OpenMedia opens the uploaded file of a video. The caller closes it.
+func (service *videoService) OpenMedia(id string) (io.ReadSeekCloser, entity.Video, error) {
+	video, err := service.FindByID(id)
+	if err != nil {
+		return nil, entity.Video{}, err
+	}
+	if video.Media == nil {
+		return nil, entity.Video{}, ErrMediaNotFound
+	}
+
+	file, _, err := service.blobs.Get(context.Background(), video.Media.Key)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			return nil, entity.Video{}, ErrMediaNotFound
+		}
+		return nil, entity.Video{}, err
+	}
+	return file, video, nil
+}
+
+func (service *videoService) deleteBlob(ctx context.Context, key string) {
+	if err := service.blobs.Delete(ctx, key); err != nil {
+		fmt.Printf("Error deleting blob %s: %v\n", key, err)
+	}
+}
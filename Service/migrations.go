@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	entity "videoAPI/Entity"
+)
+
+// migration upgrades stored videos to the schema version it is listed at.
+// Migrations must be idempotent: a crash halfway through is recovered by
+// running the same migration again.
+type migration struct {
+	description string
+	apply       func(ctx context.Context, videos *mongo.Collection, version int) error
+}
+
+// videoMigrations is applied in order; the schema version of a document is
+// the number of migrations it has been through.
+var videoMigrations = []migration{
+	{
+		description: "add tags, visibility and timestamps",
+		apply: func(ctx context.Context, videos *mongo.Collection, version int) error {
+			now := time.Now().UTC()
+			_, err := videos.UpdateMany(ctx, outdated(version), bson.M{"$set": bson.M{
+				"tags":           []string{},
+				"visibility":     entity.VisibilityPublic,
+				"created_at":     now,
+				"updated_at":     now,
+				"schema_version": version,
+			}})
+			return err
+		},
+	},
+	{
+		description: "assign server-generated IDs to videos without one",
+		apply: func(ctx context.Context, videos *mongo.Collection, version int) error {
+			filter := outdated(version)
+			filter["$or"] = []bson.M{{"id": ""}, {"id": bson.M{"$exists": false}}}
+
+			cursor, err := videos.Find(ctx, filter)
+			if err != nil {
+				return err
+			}
+			defer cursor.Close(ctx)
+
+			for cursor.Next(ctx) {
+				var doc struct {
+					ObjectID primitive.ObjectID `bson:"_id"`
+				}
+				if err := cursor.Decode(&doc); err != nil {
+					return err
+				}
+				id, err := newVideoID()
+				if err != nil {
+					return err
+				}
+				_, err = videos.UpdateOne(ctx, bson.M{"_id": doc.ObjectID}, bson.M{"$set": bson.M{"id": id}})
+				if err != nil {
+					return err
+				}
+			}
+			if err := cursor.Err(); err != nil {
+				return err
+			}
+
+			_, err = videos.UpdateMany(ctx, outdated(version), bson.M{"$set": bson.M{"schema_version": version}})
+			return err
+		},
+	},
+	{
+		description: "start optimistic concurrency versions at 1",
+		apply: func(ctx context.Context, videos *mongo.Collection, version int) error {
+			_, err := videos.UpdateMany(ctx, outdated(version), bson.M{"$set": bson.M{
+				"version":        1,
+				"schema_version": version,
+			}})
+			return err
+		},
+	},
+	{
+		description: "assign new IDs to videos sharing one",
+		apply: func(ctx context.Context, videos *mongo.Collection, version int) error {
+			pending, err := videos.CountDocuments(ctx, outdated(version))
+			if err != nil || pending == 0 {
+				return err
+			}
+			if err := reassignDuplicateIDs(ctx, videos); err != nil {
+				return err
+			}
+			_, err = videos.UpdateMany(ctx, outdated(version), bson.M{"$set": bson.M{"schema_version": version}})
+			return err
+		},
+	},
+}
+
+// reassignDuplicateIDs gives a new ID to every video but the oldest of those
+// sharing one, which the client-supplied IDs of old could leave behind. The
+// unique index on id cannot be built until they are gone.
+func reassignDuplicateIDs(ctx context.Context, videos *mongo.Collection) error {
+	cursor, err := videos.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$group", Value: bson.M{"_id": "$id", "docs": bson.M{"$push": "$_id"}}}},
+		{{Key: "$match", Value: bson.M{"docs.1": bson.M{"$exists": true}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var group struct {
+			Docs []primitive.ObjectID `bson:"docs"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return err
+		}
+		for _, objectID := range group.Docs[1:] {
+			id, err := newVideoID()
+			if err != nil {
+				return err
+			}
+			_, err = videos.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$set": bson.M{"id": id}})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return cursor.Err()
+}
+
+// currentSchemaVersion is stamped on newly saved videos.
+var currentSchemaVersion = len(videoMigrations)
+
+func outdated(version int) bson.M {
+	return bson.M{"schema_version": bson.M{"$not": bson.M{"$gte": version}}}
+}
+
+func (service *videoService) Migrate() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	for i, m := range videoMigrations {
+		version := i + 1
+		if err := m.apply(ctx, service.videoCollection, version); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", version, m.description, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	entity "videoAPI/Entity"
+)
+
+// isoMediaTypes are the upload types stored in ISO base media (MP4) files,
+// whose metadata probeMP4 can read.
+var isoMediaTypes = map[string]bool{
+	"video/mp4":       true,
+	"video/quicktime": true,
+	"video/3gpp":      true,
+	"video/3gpp2":     true,
+	"video/x-m4v":     true,
+}
+
+const (
+	// maxBoxDepth bounds how deeply boxes may nest, so a crafted file cannot
+	// recurse without end.
+	maxBoxDepth = 8
+	// maxLeafBox is the largest metadata box read into memory.
+	maxLeafBox = 1 << 20
+)
+
+// mp4Epoch is the zero of MP4 timestamps.
+var mp4Epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// mp4Probe is what probeMP4 learns about a file.
+type mp4Probe struct {
+	Info     entity.MediaInfo
+	Duration float64
+}
+
+func errInvalidMP4(format string, args ...interface{}) error {
+	return Validation("invalid_mp4", "Video file is not a valid MP4: "+fmt.Sprintf(format, args...))
+}
+
+type mp4Box struct {
+	Type   string
+	Start  int64 // offset of the payload
+	End    int64
+	Header int64
+}
+
+type mp4Parser struct {
+	reader io.ReadSeeker
+	probe  mp4Probe
+	found  bool // mvhd seen
+	tracks int
+
+	// state of the track being read
+	handler string
+	width   int
+	height  int
+}
+
+// probeMP4 reads the box structure of an MP4 file of the given size and
+// returns its duration, resolution, codecs and creation time.
+func probeMP4(reader io.ReadSeeker, size int64) (mp4Probe, error) {
+	parser := &mp4Parser{reader: reader}
+	if err := parser.walk(0, size, 0); err != nil {
+		return mp4Probe{}, err
+	}
+	if !parser.found {
+		return mp4Probe{}, errInvalidMP4("no movie header")
+	}
+	if parser.tracks == 0 {
+		return mp4Probe{}, errInvalidMP4("no tracks")
+	}
+	return parser.probe, nil
+}
+
+func (parser *mp4Parser) readBox(offset, end int64) (mp4Box, error) {
+	if _, err := parser.reader.Seek(offset, io.SeekStart); err != nil {
+		return mp4Box{}, err
+	}
+	var header [16]byte
+	if _, err := io.ReadFull(parser.reader, header[:8]); err != nil {
+		return mp4Box{}, errInvalidMP4("truncated box header at %d", offset)
+	}
+
+	size := int64(binary.BigEndian.Uint32(header[:4]))
+	box := mp4Box{Type: string(header[4:8]), Header: 8}
+	switch size {
+	case 0:
+		size = end - offset
+	case 1:
+		if _, err := io.ReadFull(parser.reader, header[8:16]); err != nil {
+			return mp4Box{}, errInvalidMP4("truncated box header at %d", offset)
+		}
+		size = int64(binary.BigEndian.Uint64(header[8:16]))
+		box.Header = 16
+	}
+	if size < box.Header || size > end-offset {
+		return mp4Box{}, errInvalidMP4("%q box at %d has an invalid size", box.Type, offset)
+	}
+
+	box.Start, box.End = offset+box.Header, offset+size
+	return box, nil
+}
+
+func (parser *mp4Parser) payload(box mp4Box) ([]byte, error) {
+	if box.End-box.Start > maxLeafBox {
+		return nil, errInvalidMP4("%q box is too large", box.Type)
+	}
+	data := make([]byte, box.End-box.Start)
+	if _, err := parser.reader.Seek(box.Start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(parser.reader, data); err != nil {
+		return nil, errInvalidMP4("%q box is truncated", box.Type)
+	}
+	return data, nil
+}
+
+// walk reads the boxes between offset and end, descending into the
+// containers that hold the metadata.
+func (parser *mp4Parser) walk(offset, end int64, depth int) error {
+	if depth > maxBoxDepth {
+		return errInvalidMP4("boxes nested too deeply")
+	}
+	for offset < end {
+		box, err := parser.readBox(offset, end)
+		if err != nil {
+			return err
+		}
+
+		switch box.Type {
+		case "moov", "mdia", "minf", "stbl":
+			err = parser.walk(box.Start, box.End, depth+1)
+		case "trak":
+			err = parser.track(box, depth)
+		case "mvhd", "tkhd", "hdlr", "stsd":
+			var data []byte
+			if data, err = parser.payload(box); err == nil {
+				err = parser.leaf(box.Type, data)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		offset = box.End
+	}
+	return nil
+}
+
+func (parser *mp4Parser) track(box mp4Box, depth int) error {
+	parser.handler, parser.width, parser.height = "", 0, 0
+	if err := parser.walk(box.Start, box.End, depth+1); err != nil {
+		return err
+	}
+	parser.tracks++
+	if parser.handler == "vide" && parser.probe.Info.Width == 0 {
+		parser.probe.Info.Width, parser.probe.Info.Height = parser.width, parser.height
+	}
+	return nil
+}
+
+func (parser *mp4Parser) leaf(boxType string, data []byte) error {
+	if len(data) < 4 {
+		return errInvalidMP4("%q box is truncated", boxType)
+	}
+	version, body := data[0], data[4:]
+
+	switch boxType {
+	case "mvhd":
+		var created uint64
+		var timescale uint32
+		var duration uint64
+		if version == 1 {
+			if len(body) < 28 {
+				return errInvalidMP4("mvhd box is truncated")
+			}
+			created = binary.BigEndian.Uint64(body[0:8])
+			timescale = binary.BigEndian.Uint32(body[16:20])
+			duration = binary.BigEndian.Uint64(body[20:28])
+		} else {
+			if len(body) < 16 {
+				return errInvalidMP4("mvhd box is truncated")
+			}
+			created = uint64(binary.BigEndian.Uint32(body[0:4]))
+			timescale = binary.BigEndian.Uint32(body[8:12])
+			duration = uint64(binary.BigEndian.Uint32(body[12:16]))
+		}
+		if timescale == 0 {
+			return errInvalidMP4("movie header has no timescale")
+		}
+		parser.found = true
+		parser.probe.Duration = float64(duration) / float64(timescale)
+		if created > 0 {
+			recorded := mp4Epoch.Add(time.Duration(created) * time.Second)
+			parser.probe.Info.RecordedAt = &recorded
+		}
+
+	case "tkhd":
+		// Width and height are 16.16 fixed point at the end of the box
+		if len(data) < 84 {
+			return errInvalidMP4("tkhd box is truncated")
+		}
+		parser.width = int(binary.BigEndian.Uint32(data[len(data)-8:]) >> 16)
+		parser.height = int(binary.BigEndian.Uint32(data[len(data)-4:]) >> 16)
+
+	case "hdlr":
+		if len(body) < 8 {
+			return errInvalidMP4("hdlr box is truncated")
+		}
+		parser.handler = string(body[4:8])
+
+	case "stsd":
+		return parser.sampleDescription(body)
+	}
+	return nil
+}
+
+// sampleDescription reads the codec of the first sample entry of a track.
+func (parser *mp4Parser) sampleDescription(body []byte) error {
+	if len(body) < 12 {
+		return errInvalidMP4("stsd box is truncated")
+	}
+	entry := body[4:]
+	size := int(binary.BigEndian.Uint32(entry[0:4]))
+	if size < 8 || size > len(entry) {
+		return errInvalidMP4("sample entry has an invalid size")
+	}
+	format, entry := string(entry[4:8]), entry[8:size]
+
+	switch parser.handler {
+	case "vide":
+		// Visual sample entries carry 70 bytes of fields before their boxes
+		if len(entry) < 70 {
+			return errInvalidMP4("%s sample entry is truncated", format)
+		}
+		if parser.width == 0 {
+			parser.width = int(binary.BigEndian.Uint16(entry[24:26]))
+			parser.height = int(binary.BigEndian.Uint16(entry[26:28]))
+		}
+		if parser.probe.Info.VideoCodec == "" {
+			parser.probe.Info.VideoCodec = videoCodec(format, childBox(entry[70:], "avcC"))
+		}
+	case "soun":
+		// Audio sample entries carry 20 bytes of fields before their boxes
+		if len(entry) < 20 {
+			return errInvalidMP4("%s sample entry is truncated", format)
+		}
+		if parser.probe.Info.AudioCodec == "" {
+			parser.probe.Info.AudioCodec = audioCodec(format, childBox(entry[20:], "esds"))
+		}
+	}
+	return nil
+}
+
+// childBox returns the payload of the first box of the given type in data,
+// or nil.
+func childBox(data []byte, boxType string) []byte {
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data[0:4]))
+		if size < 8 || size > len(data) {
+			return nil
+		}
+		if string(data[4:8]) == boxType {
+			return data[8:size]
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+// videoCodec names a video format as in an RFC 6381 codecs parameter, with
+// the profile and level of H.264 streams.
+func videoCodec(format string, avcC []byte) string {
+	if (format == "avc1" || format == "avc3") && len(avcC) >= 4 {
+		return fmt.Sprintf("%s.%02x%02x%02x", format, avcC[1], avcC[2], avcC[3])
+	}
+	return format
+}
+
+// audioCodec names an audio format as in an RFC 6381 codecs parameter, with
+// the object and audio object types of MPEG-4 audio, such as mp4a.40.2 for
+// AAC-LC.
+func audioCodec(format string, esds []byte) string {
+	if format != "mp4a" || len(esds) < 4 {
+		return format
+	}
+	data := esds[4:]
+
+	objectType, audioType := -1, -1
+	for len(data) >= 2 {
+		tag := data[0]
+		length, rest := 0, data[1:]
+		for i := 0; i < 4 && len(rest) > 0; i++ {
+			b := rest[0]
+			rest = rest[1:]
+			length = length<<7 | int(b&0x7f)
+			if b&0x80 == 0 {
+				break
+			}
+		}
+		if length > len(rest) {
+			break
+		}
+
+		switch tag {
+		case 0x03: // ES descriptor: ID, flags, optional fields, then children
+			if length < 3 {
+				return format
+			}
+			flags, skip := rest[2], 3
+			if flags&0x80 != 0 {
+				skip += 2
+			}
+			if flags&0x40 != 0 && skip < length {
+				skip += 1 + int(rest[skip])
+			}
+			if flags&0x20 != 0 {
+				skip += 2
+			}
+			if skip > length {
+				return format
+			}
+			data = rest[skip:length]
+			continue
+		case 0x04: // decoder config: object type, 12 more bytes, then children
+			if length < 13 {
+				return format
+			}
+			objectType = int(rest[0])
+			data = rest[13:length]
+			continue
+		case 0x05: // decoder specific info: audio object type in the top 5 bits
+			if length > 0 {
+				audioType = int(rest[0] >> 3)
+			}
+		}
+		data = rest[length:]
+	}
+
+	switch {
+	case objectType < 0:
+		return format
+	case audioType > 0:
+		return fmt.Sprintf("%s.%x.%d", format, objectType, audioType)
+	default:
+		return fmt.Sprintf("%s.%x", format, objectType)
+	}
+}
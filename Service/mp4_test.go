@@ -0,0 +1,88 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func box(boxType string, parts ...[]byte) []byte {
+	payload := bytes.Join(parts, nil)
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(8+len(payload)))
+	copy(header[4:], boxType)
+	return append(header, payload...)
+}
+
+func u16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+
+func zeros(n int) []byte { return make([]byte, n) }
+
+func testTrack(handler string, entry []byte) []byte {
+	tkhd := bytes.Join([][]byte{zeros(4), zeros(72), u32(1280 << 16), u32(720 << 16)}, nil)
+	if handler != "vide" {
+		tkhd = bytes.Join([][]byte{zeros(4), zeros(80)}, nil)
+	}
+	stsd := box("stsd", zeros(4), u32(1), entry)
+	return box("trak",
+		box("tkhd", tkhd),
+		box("mdia",
+			box("hdlr", zeros(4), zeros(4), []byte(handler), zeros(12)),
+			box("minf", box("stbl", stsd))))
+}
+
+func testMP4() []byte {
+	// 2020-01-02 03:04:05 UTC in seconds since 1904
+	created := uint32(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC).Sub(mp4Epoch) / time.Second)
+	mvhd := box("mvhd", zeros(4), u32(created), u32(created), u32(1000), u32(12500), zeros(80))
+
+	avc1 := box("avc1", zeros(24), u16(1280), u16(720), zeros(42),
+		box("avcC", []byte{1, 0x64, 0x00, 0x1f, 0xff}))
+	esds := box("esds", zeros(4),
+		[]byte{0x03, 0x80, 0x80, 0x80, 22, 0x00, 0x01, 0x00},
+		[]byte{0x04, 17, 0x40, 0x15}, zeros(11),
+		[]byte{0x05, 2, 0x12, 0x10})
+	mp4a := box("mp4a", zeros(20), esds)
+
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("isom"), u32(0), []byte("isomavc1")),
+		box("mdat", zeros(64)),
+		box("moov", mvhd, testTrack("vide", avc1), testTrack("soun", mp4a)),
+	}, nil)
+}
+
+func TestProbeMP4(t *testing.T) {
+	file := testMP4()
+	probe, err := probeMP4(bytes.NewReader(file), int64(len(file)))
+	require.NoError(t, err)
+
+	assert.Equal(t, 12.5, probe.Duration)
+	assert.Equal(t, 1280, probe.Info.Width)
+	assert.Equal(t, 720, probe.Info.Height)
+	assert.Equal(t, "avc1.64001f", probe.Info.VideoCodec)
+	assert.Equal(t, "mp4a.40.2", probe.Info.AudioCodec)
+	require.NotNil(t, probe.Info.RecordedAt)
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), *probe.Info.RecordedAt)
+}
+
+func TestProbeMP4Rejects(t *testing.T) {
+	valid := testMP4()
+	noMoov := box("ftyp", []byte("isom"), u32(0))
+	oversized := append(box("ftyp", []byte("isom")), 0, 0, 1, 0, 'f', 'r', 'e', 'e')
+	truncated := valid[:len(valid)-10]
+
+	for name, file := range map[string][]byte{
+		"no moov":   noMoov,
+		"oversized": oversized,
+		"truncated": truncated,
+		"garbage":   []byte("\x00\x00\x00"),
+	} {
+		_, err := probeMP4(bytes.NewReader(file), int64(len(file)))
+		assert.ErrorIs(t, err, Validation("invalid_mp4", ""), name)
+	}
+}
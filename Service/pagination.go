@@ -0,0 +1,58 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// MaxPage bounds page numbers, so that the offset of a page cannot overflow
+// and deep listings are read with a cursor instead.
+const (
+	DefaultPerPage = 10
+	MaxPerPage     = 100
+	MaxPage        = 10000
+)
+
+// SearchRequest selects one page of a video listing. When Cursor is set it
+// takes precedence over Page and the page is read by key rather than by
+// skipping over earlier results. Facets asks for counts over all matches.
+// Fuzzy matches title and tag words despite typos, see fuzzyIndex.
+type SearchRequest struct {
+	Query   string
+	Filter  VideoFilter
+	Sort    string
+	Page    int
+	PerPage int
+	Cursor  string
+	Facets  bool
+	Fuzzy   bool
+}
+
+// pageCursor marks the last item of a page by its sort key and ID. Sort
+// names the ordering it was issued for, so a cursor cannot be replayed
+// against a different one.
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v,omitempty"`
+	ID    string `json:"id"`
+}
+
+var ErrInvalidCursor = Validation("invalid_cursor", "Cursor is malformed or does not match this query")
+
+func encodeCursor(cursor pageCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(encoded, sort string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return pageCursor{}, ErrInvalidCursor
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.Sort != sort || cursor.ID == "" {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
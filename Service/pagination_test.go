@@ -0,0 +1,29 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	encoded := encodeCursor(pageCursor{Sort: "id", ID: "018f"})
+
+	cursor, err := decodeCursor(encoded, "id")
+	assert.NoError(t, err)
+	assert.Equal(t, "018f", cursor.ID)
+
+	_, err = decodeCursor(encoded, "title")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = decodeCursor("not base64!", "id")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestSearchAndPaginateBoundsPage(t *testing.T) {
+	service := &videoService{}
+	for _, page := range []int{0, MaxPage + 1, int(^uint(0) >> 1)} {
+		_, err := service.SearchAndPaginate(SearchRequest{Page: page, PerPage: DefaultPerPage})
+		assert.ErrorIs(t, err, Validation("invalid_page", ""), "page %d", page)
+	}
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	entity "videoAPI/Entity"
+)
+
+// UploadExpiry is how long a resumable upload may take, counted from its
+// creation. It is set once at startup.
+var UploadExpiry = 24 * time.Hour
+
+// ErrUploadTooLong reports a chunk running past the declared upload length.
+var ErrUploadTooLong = TooLarge("upload_length_exceeded", "Upload is longer than its declared length")
+
+// attachRetries bounds how often a finished upload is attached again after
+// losing a race with another write to the video.
+const attachRetries = 3
+
+// CreateUpload starts a resumable upload of length bytes for the video with
+// the given ID.
+func (service *videoService) CreateUpload(videoID string, length int64, metadata map[string]string, owner string) (entity.Upload, error) {
+	if length < 1 {
+		return entity.Upload{}, Validation("invalid_upload_length", "Upload-Length must be a positive number")
+	}
+	if length > MaxMediaBytes {
+		return entity.Upload{}, ErrMediaTooLarge
+	}
+	if _, err := service.FindByID(videoID); err != nil {
+		return entity.Upload{}, err
+	}
+
+	id, err := newVideoID()
+	if err != nil {
+		return entity.Upload{}, Internal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	upload := entity.Upload{
+		ID:        id,
+		VideoID:   videoID,
+		Owner:     owner,
+		Length:    length,
+		Metadata:  metadata,
+		Parts:     []entity.UploadPart{},
+		CreatedAt: now,
+		ExpiresAt: now.Add(UploadExpiry),
+	}
+	if err := service.uploads.Create(upload); err != nil {
+		return entity.Upload{}, Internal(err)
+	}
+	return upload, nil
+}
+
+// FindUpload returns an upload of owner. Uploads of other users are
+// reported as not found.
+func (service *videoService) FindUpload(id, owner string) (entity.Upload, error) {
+	upload, err := service.uploads.Get(id)
+	if err != nil {
+		return entity.Upload{}, AsError(err)
+	}
+	if upload.Owner != owner || time.Now().After(upload.ExpiresAt) {
+		return entity.Upload{}, ErrUploadNotFound
+	}
+	return upload, nil
+}
+
+// WriteUpload stores body as the next part of an upload, which must have
+// received exactly offset bytes so far. A body cut off by a dropped
+// connection is stored as far as it arrived, so the client resumes after it
+// rather than from the start. Each write stores its part under a key of its
+// own, so a retry racing the original cannot overwrite the part the other
+// one adds. The part that completes the upload attaches the file to the
+// video, pointing its URL at url; a write at the end of an upload whose file
+// could not be attached tries again.
+func (service *videoService) WriteUpload(id, owner string, offset int64, body io.Reader, url string) (entity.Upload, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	upload, err := service.FindUpload(id, owner)
+	if err != nil {
+		return entity.Upload{}, err
+	}
+	if upload.CompletedAt != nil || offset != upload.Offset {
+		return entity.Upload{}, ErrUploadOffsetMismatch
+	}
+	if upload.Offset == upload.Length {
+		// Every byte arrived but attaching the file failed
+		return service.finishUpload(ctx, upload, url)
+	}
+
+	partID, err := newVideoID()
+	if err != nil {
+		return entity.Upload{}, Internal(err)
+	}
+	key := "uploads/" + id + "/" + strconv.FormatInt(offset, 10) + "-" + partID
+	received := &cutoffReader{reader: body}
+	body = &limitedReader{reader: received, remaining: upload.Length - offset, err: ErrUploadTooLong}
+	blob, err := service.blobs.Put(ctx, key, body, -1, "")
+	if err != nil {
+		if errors.Is(err, ErrUploadTooLong) {
+			return entity.Upload{}, ErrUploadTooLong
+		}
+		return entity.Upload{}, AsError(err)
+	}
+	if blob.Size == 0 {
+		service.deleteBlob(ctx, key)
+		if received.err != nil {
+			return entity.Upload{}, AsError(received.err)
+		}
+		return upload, nil
+	}
+
+	upload, err = service.uploads.AddPart(id, entity.UploadPart{Key: key, Offset: offset, Size: blob.Size})
+	if err != nil {
+		service.deleteBlob(ctx, key)
+		return entity.Upload{}, AsError(err)
+	}
+
+	if upload.Offset == upload.Length {
+		return service.finishUpload(ctx, upload, url)
+	}
+	return upload, nil
+}
+
+// cutoffReader ends a body at its first read error, keeping the error, so
+// the bytes that arrived before a connection dropped can still be stored.
+type cutoffReader struct {
+	reader io.Reader
+	err    error
+}
+
+func (c *cutoffReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	if err != nil && err != io.EOF {
+		c.err = err
+		err = io.EOF
+	}
+	return n, err
+}
+
+// finishUpload attaches a fully received upload to its video, then marks it
+// completed and removes its parts. If the file cannot be attached the upload
+// is left as it was, so another PATCH at its final offset can retry or it
+// expires with its parts.
+func (service *videoService) finishUpload(ctx context.Context, upload entity.Upload, url string) (entity.Upload, error) {
+	parts := &partsReader{ctx: ctx, blobs: service.blobs, parts: upload.Parts}
+	media, probe, err := service.storeMedia(ctx, upload.VideoID, parts)
+	parts.Close()
+	if err != nil {
+		return entity.Upload{}, err
+	}
+	if err := service.attachUpload(ctx, upload, media, probe, url); err != nil {
+		service.deleteBlob(ctx, media.Key)
+		return entity.Upload{}, err
+	}
+
+	now := time.Now().UTC()
+	completed, err := service.uploads.Complete(upload.ID, now)
+	if err != nil {
+		return entity.Upload{}, Internal(err)
+	}
+	if !completed {
+		// A retry racing this one finished it first and removes the parts
+		return entity.Upload{}, ErrUploadOffsetMismatch
+	}
+	service.deleteParts(ctx, upload)
+
+	upload.CompletedAt = &now
+	upload.Parts = []entity.UploadPart{}
+	return upload, nil
+}
+
+// attachUpload attaches media to whatever version the video is at, since an
+// upload may span many edits of it.
+func (service *videoService) attachUpload(ctx context.Context, upload entity.Upload, media entity.Media, probe *mp4Probe, url string) error {
+	var err error
+	for attempt := 0; attempt < attachRetries; attempt++ {
+		var video entity.Video
+		if video, err = service.FindByID(upload.VideoID); err != nil {
+			return err
+		}
+		if _, err = service.attachMedia(ctx, upload.VideoID, video.Version, media, probe, url, upload.Owner); !errors.Is(err, ErrVersionMismatch) {
+			return err
+		}
+	}
+	return err
+}
+
+// DeleteUpload abandons an upload and removes the parts received.
+func (service *videoService) DeleteUpload(id, owner string) error {
+	upload, err := service.FindUpload(id, owner)
+	if err != nil {
+		return err
+	}
+
+	service.deleteParts(context.Background(), upload)
+	if err := service.uploads.Delete(id); err != nil {
+		return Internal(err)
+	}
+	return nil
+}
+
+// ExpireUploads removes uploads past their expiry, with any parts they hold,
+// and reports how many were removed.
+func (service *videoService) ExpireUploads() (int64, error) {
+	expired, err := service.uploads.Expired(time.Now().UTC())
+	if err != nil {
+		return 0, Internal(err)
+	}
+
+	var removed int64
+	for _, upload := range expired {
+		service.deleteParts(context.Background(), upload)
+		if err := service.uploads.Delete(upload.ID); err != nil {
+			return removed, Internal(err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func (service *videoService) deleteParts(ctx context.Context, upload entity.Upload) {
+	for _, part := range upload.Parts {
+		service.deleteBlob(ctx, part.Key)
+	}
+}
+
+// partsReader reads the parts of an upload one after another, opening each
+// only when the one before it is exhausted.
+type partsReader struct {
+	ctx     context.Context
+	blobs   BlobStore
+	parts   []entity.UploadPart
+	current io.ReadCloser
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+			file, _, err := r.blobs.Get(r.ctx, r.parts[0].Key)
+			if err != nil {
+				return 0, err
+			}
+			r.current, r.parts = file, r.parts[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *partsReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	entity "videoAPI/Entity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newUploadService(t *testing.T) *videoService {
+	blobs, err := NewLocalBlobStore(t.TempDir())
+	require.NoError(t, err)
+	return &videoService{blobs: blobs, uploads: NewMemoryUploadStore()}
+}
+
+func createTestUpload(t *testing.T, service *videoService, length int64, expires time.Time) {
+	require.NoError(t, service.uploads.Create(entity.Upload{ID: "u1", VideoID: "v1", Owner: "a@b.c", Length: length, ExpiresAt: expires}))
+}
+
+func TestWriteUploadResumesAtOffset(t *testing.T) {
+	service := newUploadService(t)
+	createTestUpload(t, service, 10, time.Now().Add(time.Hour))
+
+	upload, err := service.WriteUpload("u1", "a@b.c", 0, strings.NewReader("hello"), "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), upload.Offset)
+
+	_, err = service.WriteUpload("u1", "a@b.c", 0, strings.NewReader("hello"), "")
+	assert.ErrorIs(t, err, ErrUploadOffsetMismatch)
+
+	_, err = service.WriteUpload("u1", "a@b.c", 5, strings.NewReader("world!"), "")
+	assert.ErrorIs(t, err, ErrUploadTooLong)
+
+	_, err = service.WriteUpload("u1", "x@y.z", 5, strings.NewReader("world"), "")
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+
+	upload, err = service.FindUpload("u1", "a@b.c")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), upload.Offset)
+
+	body, err := io.ReadAll(&partsReader{ctx: context.Background(), blobs: service.blobs, parts: upload.Parts})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+}
+
+func TestWriteUploadKeepsBytesBeforeDisconnect(t *testing.T) {
+	service := newUploadService(t)
+	createTestUpload(t, service, 10, time.Now().Add(time.Hour))
+
+	cutOff := io.MultiReader(strings.NewReader("hel"), iotest.ErrReader(errors.New("connection reset by peer")))
+	upload, err := service.WriteUpload("u1", "a@b.c", 0, cutOff, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), upload.Offset)
+
+	_, err = service.WriteUpload("u1", "a@b.c", 0, iotest.ErrReader(errors.New("connection reset by peer")), "")
+	assert.ErrorIs(t, err, ErrUploadOffsetMismatch)
+	_, err = service.WriteUpload("u1", "a@b.c", 3, iotest.ErrReader(errors.New("connection reset by peer")), "")
+	assert.Error(t, err)
+
+	upload, err = service.WriteUpload("u1", "a@b.c", 3, strings.NewReader("lo"), "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), upload.Offset)
+
+	body, err := io.ReadAll(&partsReader{ctx: context.Background(), blobs: service.blobs, parts: upload.Parts})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+}
+
+// racingUploadStore lets another write at the same offset win while the
+// first one is between storing its part and adding it.
+type racingUploadStore struct {
+	UploadStore
+	race func()
+}
+
+func (store *racingUploadStore) AddPart(id string, part entity.UploadPart) (entity.Upload, error) {
+	if race := store.race; race != nil {
+		store.race = nil
+		race()
+	}
+	return store.UploadStore.AddPart(id, part)
+}
+
+func TestWriteUploadRetryRace(t *testing.T) {
+	service := newUploadService(t)
+	createTestUpload(t, service, 10, time.Now().Add(time.Hour))
+	store := &racingUploadStore{UploadStore: service.uploads}
+	service.uploads = store
+	store.race = func() {
+		_, err := service.WriteUpload("u1", "a@b.c", 0, strings.NewReader("hello"), "")
+		require.NoError(t, err)
+	}
+
+	_, err := service.WriteUpload("u1", "a@b.c", 0, strings.NewReader("HELLO"), "")
+	assert.ErrorIs(t, err, ErrUploadOffsetMismatch)
+
+	upload, err := service.FindUpload("u1", "a@b.c")
+	require.NoError(t, err)
+	body, err := io.ReadAll(&partsReader{ctx: context.Background(), blobs: service.blobs, parts: upload.Parts})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+}
+
+func TestPartsReaderJoinsParts(t *testing.T) {
+	service := newUploadService(t)
+	for _, part := range []string{"ab", "cd", "e"} {
+		_, err := service.blobs.Put(context.Background(), part, strings.NewReader(part), -1, "")
+		require.NoError(t, err)
+	}
+
+	parts := &partsReader{ctx: context.Background(), blobs: service.blobs, parts: []entity.UploadPart{{Key: "ab"}, {Key: "cd"}, {Key: "e"}}}
+	body, err := io.ReadAll(parts)
+	require.NoError(t, err)
+	assert.Equal(t, "abcde", string(body))
+}
+
+func TestFinishUploadFailureKeepsParts(t *testing.T) {
+	service := newUploadService(t)
+	createTestUpload(t, service, 10, time.Now().Add(time.Hour))
+
+	_, err := service.WriteUpload("u1", "a@b.c", 0, strings.NewReader("not a mp4!"), "")
+	assert.ErrorIs(t, err, Unsupported("unsupported_media", ""))
+
+	upload, err := service.FindUpload("u1", "a@b.c")
+	require.NoError(t, err)
+	assert.Nil(t, upload.CompletedAt)
+	assert.Equal(t, int64(10), upload.Offset)
+	require.Len(t, upload.Parts, 1)
+
+	_, err = service.WriteUpload("u1", "a@b.c", 10, strings.NewReader(""), "")
+	assert.ErrorIs(t, err, Unsupported("unsupported_media", ""), "a write at the end retries attaching")
+	_, _, err = service.blobs.Get(context.Background(), upload.Parts[0].Key)
+	assert.NoError(t, err)
+}
+
+func TestExpireUploadsRemovesParts(t *testing.T) {
+	service := newUploadService(t)
+	createTestUpload(t, service, 10, time.Now().Add(time.Hour))
+	_, err := service.WriteUpload("u1", "a@b.c", 0, strings.NewReader("hello"), "")
+	require.NoError(t, err)
+
+	removed, err := service.ExpireUploads()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), removed)
+
+	upload, _ := service.uploads.Get("u1")
+	upload.ExpiresAt = time.Now().Add(-time.Minute)
+	require.NoError(t, service.uploads.Create(upload))
+
+	removed, err = service.ExpireUploads()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+
+	_, err = service.uploads.Get("u1")
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+	_, _, err = service.blobs.Get(context.Background(), upload.Parts[0].Key)
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+}
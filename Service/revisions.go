@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	entity "videoAPI/Entity"
+)
+
+var ErrRevisionNotFound = NotFound("revision_not_found", "Revision not found")
+
+// RevisionStore keeps the history of video writes. It is independent of
+// where the videos themselves are stored.
+type RevisionStore interface {
+	Add(revision entity.Revision) error
+	List(videoID string) ([]entity.Revision, error)
+	Get(videoID string, number int64) (entity.Revision, error)
+}
+
+type mongoRevisionStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoRevisionStore(collection *mongo.Collection) RevisionStore {
+	return &mongoRevisionStore{collection: collection}
+}
+
+func (store *mongoRevisionStore) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := store.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "video_id", Value: 1}, {Key: "number", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (store *mongoRevisionStore) Add(revision entity.Revision) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := store.collection.InsertOne(ctx, revision)
+	return err
+}
+
+func (store *mongoRevisionStore) List(videoID string) ([]entity.Revision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "number", Value: -1}})
+	cursor, err := store.collection.Find(ctx, bson.M{"video_id": videoID}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	revisions := []entity.Revision{}
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (store *mongoRevisionStore) Get(videoID string, number int64) (entity.Revision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var revision entity.Revision
+	err := store.collection.FindOne(ctx, bson.M{"video_id": videoID, "number": number}).Decode(&revision)
+	if err == mongo.ErrNoDocuments {
+		return entity.Revision{}, ErrRevisionNotFound
+	}
+	return revision, err
+}
+
+type memoryRevisionStore struct {
+	mu        sync.RWMutex
+	revisions map[string][]entity.Revision
+}
+
+// NewMemoryRevisionStore keeps revisions in process memory, for tests and
+// single-instance deployments without Mongo.
+func NewMemoryRevisionStore() RevisionStore {
+	return &memoryRevisionStore{revisions: map[string][]entity.Revision{}}
+}
+
+func (store *memoryRevisionStore) Add(revision entity.Revision) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.revisions[revision.VideoID] = append(store.revisions[revision.VideoID], revision)
+	return nil
+}
+
+func (store *memoryRevisionStore) List(videoID string) ([]entity.Revision, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	revisions := append([]entity.Revision{}, store.revisions[videoID]...)
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Number > revisions[j].Number })
+	return revisions, nil
+}
+
+func (store *memoryRevisionStore) Get(videoID string, number int64) (entity.Revision, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	for _, revision := range store.revisions[videoID] {
+		if revision.Number == number {
+			return revision, nil
+		}
+	}
+	return entity.Revision{}, ErrRevisionNotFound
+}
+
+// diffVideos lists the client-editable fields that differ between before and
+// after, keyed by JSON name.
+func diffVideos(before, after entity.Video) map[string]entity.FieldChange {
+	beforeFields, afterFields := jsonFields(before), jsonFields(after)
+
+	changes := map[string]entity.FieldChange{}
+	for _, field := range entity.EditableVideoFields {
+		if !reflect.DeepEqual(beforeFields[field], afterFields[field]) {
+			changes[field] = entity.FieldChange{From: beforeFields[field], To: afterFields[field]}
+		}
+	}
+	return changes
+}
+
+func jsonFields(video entity.Video) map[string]interface{} {
+	raw, _ := json.Marshal(video)
+	fields := map[string]interface{}{}
+	json.Unmarshal(raw, &fields)
+	return fields
+}
+
+// recordRevision stores the revision produced by a write from before to
+// after. The write has already happened, so a failure here is only logged.
+func (service *videoService) recordRevision(before, after entity.Video, actor string) {
+	revision := entity.Revision{
+		VideoID:   after.ID,
+		Number:    after.Version,
+		Actor:     actor,
+		Timestamp: after.UpdatedAt,
+		Changes:   diffVideos(before, after),
+		Video:     after,
+	}
+	if err := service.revisions.Add(revision); err != nil {
+		fmt.Printf("Error recording revision %d of video %s: %v\n", revision.Number, revision.VideoID, err)
+	}
+}
+
+func (service *videoService) FindRevisions(id string) ([]entity.Revision, error) {
+	if _, err := service.FindByID(id); err != nil {
+		return nil, err
+	}
+
+	revisions, err := service.revisions.List(id)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	return revisions, nil
+}
+
+// RevertToRevision writes the client-editable fields of revision number back
+// onto the video, which must still be at version. The revert is itself
+// recorded as a new revision.
+func (service *videoService) RevertToRevision(id string, number int64, version int64, actor string) (entity.Video, error) {
+	video, err := service.FindByID(id)
+	if err != nil {
+		return entity.Video{}, err
+	}
+
+	revision, err := service.revisions.Get(id, number)
+	if err != nil {
+		return entity.Video{}, AsError(err)
+	}
+
+	snapshot := revision.Video
+	video.Title = snapshot.Title
+	video.Description = snapshot.Description
+	video.URL = snapshot.URL
+	video.Tags = snapshot.Tags
+	video.Category = snapshot.Category
+	if video.Media == nil {
+		// The duration of an uploaded file is read from it
+		video.Duration = snapshot.Duration
+	}
+	video.Language = snapshot.Language
+	video.ThumbnailURL = snapshot.ThumbnailURL
+	video.Visibility = snapshot.Visibility
+	video.Version = version
+
+	if err := service.Update(&video, actor); err != nil {
+		return entity.Video{}, err
+	}
+	return video, nil
+}
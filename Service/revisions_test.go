@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+
+	entity "videoAPI/Entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffVideos(t *testing.T) {
+	before := entity.Video{ID: "1", Title: "old", URL: "https://a.example", Tags: []string{"a"}, Version: 1}
+	after := before
+	after.Title = "new"
+	after.Tags = []string{"a", "b"}
+	after.Version = 2
+
+	changes := diffVideos(before, after)
+
+	assert.Len(t, changes, 2)
+	assert.Equal(t, entity.FieldChange{From: "old", To: "new"}, changes["title"])
+	assert.Contains(t, changes, "tags")
+	assert.NotContains(t, changes, "version")
+}
+
+func TestMemoryRevisionStore(t *testing.T) {
+	store := NewMemoryRevisionStore()
+	assert.NoError(t, store.Add(entity.Revision{VideoID: "1", Number: 1}))
+	assert.NoError(t, store.Add(entity.Revision{VideoID: "1", Number: 2, Actor: "a@b.c"}))
+	assert.NoError(t, store.Add(entity.Revision{VideoID: "2", Number: 1}))
+
+	revisions, err := store.List("1")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 1}, []int64{revisions[0].Number, revisions[1].Number})
+
+	revision, err := store.Get("1", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "a@b.c", revision.Actor)
+
+	_, err = store.Get("1", 3)
+	assert.ErrorIs(t, err, ErrRevisionNotFound)
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	entity "videoAPI/Entity"
+)
+
+// maxSearchTerms bounds the work a single query can cause.
+const maxSearchTerms = 10
+
+// searchQuery is a parsed search string. Plain words and "quoted phrases" go
+// to the text index; words ending in * are matched as prefixes.
+type searchQuery struct {
+	Terms    []string
+	Phrases  []string
+	Prefixes []string
+}
+
+func (query searchQuery) empty() bool {
+	return len(query.Terms) == 0 && len(query.Phrases) == 0 && len(query.Prefixes) == 0
+}
+
+func parseSearchQuery(raw string) searchQuery {
+	var query searchQuery
+	count := 0
+	add := func(list *[]string, value string) {
+		if value != "" && count < maxSearchTerms {
+			*list = append(*list, value)
+			count++
+		}
+	}
+
+	rest := raw
+	for {
+		start := strings.Index(rest, `"`)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(rest[start+1:], `"`)
+		if end < 0 {
+			break
+		}
+		add(&query.Phrases, strings.Join(searchWords(rest[start+1:start+1+end]), " "))
+		rest = rest[:start] + " " + rest[start+end+2:]
+	}
+
+	for _, field := range strings.Fields(rest) {
+		if strings.HasSuffix(field, "*") {
+			words := searchWords(field)
+			if len(words) > 0 {
+				add(&query.Prefixes, words[len(words)-1])
+			}
+			continue
+		}
+		for _, word := range searchWords(field) {
+			add(&query.Terms, word)
+		}
+	}
+
+	return query
+}
+
+// searchWords splits s into words, dropping punctuation so that nothing the
+// user types is interpreted as text search or regex syntax.
+func searchWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// filter translates the query into Mongo conditions, added to base.
+func (query searchQuery) filter(base bson.M) bson.M {
+	if len(query.Terms) > 0 || len(query.Phrases) > 0 {
+		search := strings.Join(query.Terms, " ")
+		for _, phrase := range query.Phrases {
+			search += ` "` + phrase + `"`
+		}
+		base["$text"] = bson.M{"$search": strings.TrimSpace(search)}
+	}
+
+	var prefixConditions []bson.M
+	for _, prefix := range query.Prefixes {
+		pattern := `(^|\W)` + regexp.QuoteMeta(prefix)
+		prefixConditions = append(prefixConditions, bson.M{"$or": []bson.M{
+			{"title": bson.M{"$regex": pattern, "$options": "i"}},
+			{"tags": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix), "$options": "i"}},
+		}})
+	}
+	if len(prefixConditions) > 0 {
+		base["$and"] = prefixConditions
+	}
+
+	return base
+}
+
+// usesTextIndex reports whether results carry a text score to sort by.
+func (query searchQuery) usesTextIndex() bool {
+	return len(query.Terms) > 0 || len(query.Phrases) > 0
+}
+
+// highlightRadius is how many runes of context a snippet keeps on each side
+// of the first match.
+const highlightRadius = 40
+
+// highlight returns snippets of the title and description around the first
+// match of the query, or nil when neither field matches literally (the text
+// index also matches stemmed forms).
+func (query searchQuery) highlight(video entity.Video) map[string]string {
+	var patterns []string
+	for _, term := range append(append([]string{}, query.Phrases...), query.Terms...) {
+		patterns = append(patterns, `\b`+regexp.QuoteMeta(term)+`\b`)
+	}
+	for _, prefix := range query.Prefixes {
+		patterns = append(patterns, `\b`+regexp.QuoteMeta(prefix))
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+	matcher := regexp.MustCompile(`(?i)` + strings.Join(patterns, "|"))
+
+	highlights := map[string]string{}
+	for field, text := range map[string]string{"title": video.Title, "description": video.Description} {
+		if snippet, ok := snippet(text, matcher); ok {
+			highlights[field] = snippet
+		}
+	}
+	if len(highlights) == 0 {
+		return nil
+	}
+	return highlights
+}
+
+func snippet(text string, matcher *regexp.Regexp) (string, bool) {
+	matches := matcher.FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	runes := []rune(text)
+	first := len([]rune(text[:matches[0][0]]))
+	start, end := first-highlightRadius, first+highlightRadius*2
+	if start < 0 {
+		start = 0
+	}
+	if end > len(runes) {
+		end = len(runes)
+	}
+	window := string(runes[start:end])
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	last := 0
+	for _, match := range matcher.FindAllStringIndex(window, -1) {
+		b.WriteString(html.EscapeString(window[last:match[0]]))
+		b.WriteString("<mark>" + html.EscapeString(window[match[0]:match[1]]) + "</mark>")
+		last = match[1]
+	}
+	b.WriteString(html.EscapeString(window[last:]))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+
+	return b.String(), true
+}
+
+// LoadSearchIndexes builds the in-process search indexes from storage. Writes
+// through this service keep them current afterwards.
+func (service *videoService) LoadSearchIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	projection := options.Find().SetProjection(bson.M{"id": 1, "title": 1, "tags": 1, "views": 1, "visibility": 1})
+	cursor, err := service.videoCollection.Find(ctx, live(bson.M{}), projection)
+	if err != nil {
+		return Internal(err)
+	}
+	defer cursor.Close(ctx)
+
+	var videos []entity.Video
+	if err := cursor.All(ctx, &videos); err != nil {
+		return Internal(err)
+	}
+
+	service.suggestions.replace(videos)
+	service.fuzzy.replace(videos)
+	return nil
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"testing"
+
+	entity "videoAPI/Entity"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	query := parseSearchQuery(`go "gin framework" tut* .*(a+)+$`)
+
+	assert.Equal(t, []string{"go", "a"}, query.Terms)
+	assert.Equal(t, []string{"gin framework"}, query.Phrases)
+	assert.Equal(t, []string{"tut"}, query.Prefixes)
+	assert.True(t, parseSearchQuery(` "" ** `).empty())
+}
+
+func TestSearchQueryFilter(t *testing.T) {
+	filter := parseSearchQuery(`go "gin framework" tut*`).filter(bson.M{})
+
+	assert.Equal(t, bson.M{"$search": `go "gin framework"`}, filter["$text"])
+	assert.Len(t, filter["$and"], 1)
+
+	assert.Empty(t, parseSearchQuery("").filter(bson.M{}))
+}
+
+func TestSearchQueryEscapesRegex(t *testing.T) {
+	filter := parseSearchQuery(`c++*`).filter(bson.M{})
+
+	conditions := filter["$and"].([]bson.M)[0]["$or"].([]bson.M)
+	assert.Equal(t, `(^|\W)c`, conditions[0]["title"].(bson.M)["$regex"])
+}
+
+func TestHighlight(t *testing.T) {
+	video := entity.Video{
+		Title:       "Learning Go <fast>",
+		Description: "An introduction to the Go programming language and its standard library, covering goroutines.",
+	}
+
+	highlights := parseSearchQuery("go").highlight(video)
+
+	assert.Equal(t, "Learning <mark>Go</mark> &lt;fast&gt;", highlights["title"])
+	assert.Contains(t, highlights["description"], "the <mark>Go</mark> programming")
+	assert.Nil(t, parseSearchQuery("rust").highlight(video))
+}
+
+func TestHighlightMatchesWholeWords(t *testing.T) {
+	video := entity.Video{Title: "Goroutines in Go"}
+
+	assert.Equal(t, "Goroutines in <mark>Go</mark>", parseSearchQuery("go").highlight(video)["title"])
+	assert.Equal(t, "<mark>Gor</mark>outines in Go", parseSearchQuery("gor*").highlight(video)["title"])
+}
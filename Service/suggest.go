@@ -0,0 +1,337 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	entity "videoAPI/Entity"
+)
+
+const (
+	DefaultSuggestions = 10
+	MaxSuggestions     = 25
+)
+
+// suggestDepth is the length in bytes of the longest prefix with a
+// precomputed completion list. Longer prefixes are completed from the few
+// keys sharing their first suggestDepth bytes.
+const suggestDepth = 8
+
+// suggestKey is one searchable suffix of a title: the normalized title from
+// the start of one of its words to the end.
+type suggestKey struct {
+	key string
+	id  string
+}
+
+// suggestNode is the node of a prefix in the index trie. top holds the IDs of
+// the most viewed videos with a key under the node, in order, up to
+// MaxSuggestions. keys holds the keys ending at the node and, at
+// suggestDepth, every key under it.
+type suggestNode struct {
+	children map[byte]*suggestNode
+	keys     []suggestKey
+	top      []string
+}
+
+// suggestStep is a node of the trie with the parent and byte leading to it.
+type suggestStep struct {
+	node   *suggestNode
+	parent *suggestNode
+	b      byte
+	depth  int
+}
+
+// suggestIndex completes title prefixes in memory. Every word of a title
+// starts a key, so "tour" completes "A Tour of Go". Only public, live videos
+// are indexed, so completions never reveal unlisted or private titles.
+//
+// Completions of prefixes up to suggestDepth bytes are kept ranked as videos
+// are written and viewed, so a keystroke reads its answer directly.
+//
+// The index is local to the process: writes made by other instances reach it
+// only when it is next loaded.
+type suggestIndex struct {
+	mu     sync.RWMutex
+	videos map[string]entity.Suggestion
+	root   *suggestNode
+}
+
+func newSuggestIndex() *suggestIndex {
+	return &suggestIndex{videos: map[string]entity.Suggestion{}, root: &suggestNode{}}
+}
+
+// normalizeTitle lowercases s and collapses runs of white space, keeping a
+// single trailing space so that "go " completes only the whole word.
+func normalizeTitle(s string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	if normalized != "" && strings.TrimRight(s, " \t") != s {
+		normalized += " "
+	}
+	return normalized
+}
+
+// titleKeys ends every key with a space, which a prefix with a trailing
+// space can match at the end of the title as well as between words.
+func titleKeys(title string) []string {
+	normalized := normalizeTitle(strings.TrimSpace(title)) + " "
+	keys := []string{}
+	for i := 0; i < len(normalized)-1; i++ {
+		if i == 0 || normalized[i-1] == ' ' {
+			keys = append(keys, normalized[i:])
+		}
+	}
+	return keys
+}
+
+// ranksBefore reports whether video a is suggested before video b: more
+// viewed first, then by title.
+func (index *suggestIndex) ranksBefore(a, b string) bool {
+	first, second := index.videos[a], index.videos[b]
+	if first.Views != second.Views {
+		return first.Views > second.Views
+	}
+	if first.Title != second.Title {
+		return first.Title < second.Title
+	}
+	return a < b
+}
+
+// leaf returns the node holding key, creating the path to it when create is
+// set. Otherwise it returns nil if the path is missing.
+func (index *suggestIndex) leaf(key string, create bool) *suggestNode {
+	node := index.root
+	for i := 0; i < len(key) && i < suggestDepth; i++ {
+		child := node.children[key[i]]
+		if child == nil {
+			if !create {
+				return nil
+			}
+			if node.children == nil {
+				node.children = map[byte]*suggestNode{}
+			}
+			child = &suggestNode{}
+			node.children[key[i]] = child
+		}
+		node = child
+	}
+	return node
+}
+
+// steps returns the nodes below the root on the paths of the keys of title,
+// each once, deepest first.
+func (index *suggestIndex) steps(title string) []suggestStep {
+	seen := map[*suggestNode]bool{}
+	steps := []suggestStep{}
+	for _, key := range titleKeys(title) {
+		node := index.root
+		for i := 0; i < len(key) && i < suggestDepth; i++ {
+			child := node.children[key[i]]
+			if child == nil {
+				break
+			}
+			if !seen[child] {
+				seen[child] = true
+				steps = append(steps, suggestStep{node: child, parent: node, b: key[i], depth: i + 1})
+			}
+			node = child
+		}
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].depth > steps[j].depth })
+	return steps
+}
+
+// offer ranks id into the top list of node, unless the list is full of
+// videos ranked before it.
+func (index *suggestIndex) offer(node *suggestNode, id string) {
+	i := sort.Search(len(node.top), func(i int) bool { return index.ranksBefore(id, node.top[i]) })
+	if i >= MaxSuggestions {
+		return
+	}
+	node.top = append(node.top, "")
+	copy(node.top[i+1:], node.top[i:])
+	node.top[i] = id
+	if len(node.top) > MaxSuggestions {
+		node.top = node.top[:MaxSuggestions]
+	}
+}
+
+// refill recomputes the top list of node from its own keys and the top lists
+// of its children, which between them hold the best videos under it.
+func (index *suggestIndex) refill(node *suggestNode) {
+	seen := map[string]bool{}
+	candidates := []string{}
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			candidates = append(candidates, id)
+		}
+	}
+	for _, entry := range node.keys {
+		add(entry.id)
+	}
+	for _, child := range node.children {
+		for _, id := range child.top {
+			add(id)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return index.ranksBefore(candidates[i], candidates[j]) })
+	if len(candidates) > MaxSuggestions {
+		candidates = candidates[:MaxSuggestions]
+	}
+	node.top = candidates
+}
+
+// withoutID returns ids without id, reusing its array.
+func withoutID(ids []string, id string) []string {
+	for i, existing := range ids {
+		if existing == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
+
+// put indexes video, replacing any earlier entry with the same ID. Videos
+// that may not be suggested are removed instead.
+func (index *suggestIndex) put(video entity.Video) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	index.remove(video.ID)
+	if video.DeletedAt != nil || (video.Visibility != "" && video.Visibility != entity.VisibilityPublic) {
+		return
+	}
+
+	index.videos[video.ID] = entity.Suggestion{ID: video.ID, Title: video.Title, Views: video.Views}
+	for _, key := range titleKeys(video.Title) {
+		leaf := index.leaf(key, true)
+		leaf.keys = append(leaf.keys, suggestKey{key: key, id: video.ID})
+	}
+	for _, step := range index.steps(video.Title) {
+		index.offer(step.node, video.ID)
+	}
+}
+
+func (index *suggestIndex) delete(id string) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.remove(id)
+}
+
+// remove drops id from the index, pruning nodes left empty and refilling the
+// lists it leaves a gap in, children before their parents. The caller holds
+// the write lock.
+func (index *suggestIndex) remove(id string) {
+	suggestion, ok := index.videos[id]
+	if !ok {
+		return
+	}
+	delete(index.videos, id)
+
+	for _, key := range titleKeys(suggestion.Title) {
+		if leaf := index.leaf(key, false); leaf != nil {
+			for i, entry := range leaf.keys {
+				if entry.key == key && entry.id == id {
+					leaf.keys = append(leaf.keys[:i], leaf.keys[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	for _, step := range index.steps(suggestion.Title) {
+		if len(step.node.keys) == 0 && len(step.node.children) == 0 {
+			delete(step.parent.children, step.b)
+			continue
+		}
+		for _, top := range step.node.top {
+			if top == id {
+				index.refill(step.node)
+				break
+			}
+		}
+	}
+}
+
+// viewed counts a view of id towards its ranking. A view only moves a video
+// up, so it can enter full lists but never leaves one.
+func (index *suggestIndex) viewed(id string) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	suggestion, ok := index.videos[id]
+	if !ok {
+		return
+	}
+	suggestion.Views++
+	index.videos[id] = suggestion
+
+	for _, step := range index.steps(suggestion.Title) {
+		step.node.top = withoutID(step.node.top, id)
+		index.offer(step.node, id)
+	}
+}
+
+// replace swaps the whole index for one built from videos.
+func (index *suggestIndex) replace(videos []entity.Video) {
+	fresh := newSuggestIndex()
+	for _, video := range videos {
+		fresh.put(video)
+	}
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.videos, index.root = fresh.videos, fresh.root
+}
+
+// complete returns up to limit titles with a word starting with prefix, most
+// viewed first.
+func (index *suggestIndex) complete(prefix string, limit int) []entity.Suggestion {
+	prefix = normalizeTitle(strings.TrimLeft(prefix, " \t"))
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	node := index.root
+	for i := 0; i < len(prefix) && i < suggestDepth; i++ {
+		if node = node.children[prefix[i]]; node == nil {
+			return []entity.Suggestion{}
+		}
+	}
+
+	ids := node.top
+	if len(prefix) > suggestDepth {
+		seen := map[string]bool{}
+		ids = []string{}
+		for _, entry := range node.keys {
+			if strings.HasPrefix(entry.key, prefix) && !seen[entry.id] {
+				seen[entry.id] = true
+				ids = append(ids, entry.id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return index.ranksBefore(ids[i], ids[j]) })
+	}
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	matches := make([]entity.Suggestion, len(ids))
+	for i, id := range ids {
+		matches[i] = index.videos[id]
+	}
+	return matches
+}
+
+// Suggest completes prefix to the titles of the most viewed public videos.
+func (service *videoService) Suggest(prefix string, limit int) ([]entity.Suggestion, error) {
+	if strings.TrimSpace(prefix) == "" {
+		return nil, Validation("invalid_prefix", "prefix must not be empty")
+	}
+	if limit < 1 || limit > MaxSuggestions {
+		return nil, Validation("invalid_limit", fmt.Sprintf("limit must be between 1 and %d", MaxSuggestions))
+	}
+	return service.suggestions.complete(prefix, limit), nil
+}
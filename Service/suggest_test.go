@@ -0,0 +1,89 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	entity "videoAPI/Entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func suggestedTitles(suggestions []entity.Suggestion) []string {
+	titles := []string{}
+	for _, suggestion := range suggestions {
+		titles = append(titles, suggestion.Title)
+	}
+	return titles
+}
+
+func TestSuggestIndexRanksByViews(t *testing.T) {
+	index := newSuggestIndex()
+	index.put(entity.Video{ID: "1", Title: "A Tour of Go", Views: 5})
+	index.put(entity.Video{ID: "2", Title: "Gophers  at work", Views: 50})
+	index.put(entity.Video{ID: "3", Title: "Rust basics", Views: 100})
+
+	assert.Equal(t, []string{"Gophers  at work", "A Tour of Go"}, suggestedTitles(index.complete("go", 10)))
+	assert.Equal(t, []string{"A Tour of Go"}, suggestedTitles(index.complete("go ", 10)))
+	assert.Equal(t, []string{"A Tour of Go"}, suggestedTitles(index.complete("TOUR o", 10)))
+	assert.Equal(t, []string{"Gophers  at work"}, suggestedTitles(index.complete("go", 1)))
+
+	for i := 0; i < 100; i++ {
+		index.viewed("1")
+	}
+	assert.Equal(t, []string{"A Tour of Go", "Gophers  at work"}, suggestedTitles(index.complete("go", 10)))
+}
+
+func TestSuggestIndexFollowsWrites(t *testing.T) {
+	index := newSuggestIndex()
+	index.put(entity.Video{ID: "1", Title: "A Tour of Go"})
+
+	index.put(entity.Video{ID: "1", Title: "Effective Go"})
+	assert.Empty(t, index.complete("tour", 10))
+	assert.Equal(t, []string{"Effective Go"}, suggestedTitles(index.complete("eff", 10)))
+
+	index.put(entity.Video{ID: "1", Title: "Effective Go", Visibility: entity.VisibilityPrivate})
+	assert.Empty(t, index.complete("eff", 10))
+
+	deleted := time.Now()
+	index.put(entity.Video{ID: "2", Title: "Effective Go", DeletedAt: &deleted})
+	assert.Empty(t, index.complete("eff", 10))
+
+	index.put(entity.Video{ID: "3", Title: "Effective Go"})
+	index.delete("3")
+	assert.Empty(t, index.complete("eff", 10))
+	assert.Empty(t, index.root.children)
+}
+
+func TestSuggestIndexRefillsAfterRemovals(t *testing.T) {
+	index := newSuggestIndex()
+	for i := 0; i <= MaxSuggestions; i++ {
+		index.put(entity.Video{ID: fmt.Sprint(i), Title: fmt.Sprintf("Go %02d", i), Views: int64(i)})
+	}
+	assert.Len(t, index.complete("go", MaxSuggestions), MaxSuggestions)
+	assert.NotContains(t, suggestedTitles(index.complete("go", MaxSuggestions)), "Go 00")
+
+	index.delete(fmt.Sprint(MaxSuggestions))
+	titles := suggestedTitles(index.complete("go", MaxSuggestions))
+	assert.Len(t, titles, MaxSuggestions)
+	assert.Equal(t, "Go 00", titles[MaxSuggestions-1])
+
+	index.viewed("0")
+	assert.Equal(t, []string{"Go 24", "Go 23"}, suggestedTitles(index.complete("g", 2)))
+	for i := 0; i < 30; i++ {
+		index.viewed("0")
+	}
+	assert.Equal(t, []string{"Go 00", "Go 24"}, suggestedTitles(index.complete("g", 2)))
+}
+
+func TestSuggestIndexCompletesLongPrefixes(t *testing.T) {
+	index := newSuggestIndex()
+	index.put(entity.Video{ID: "1", Title: "Effective Go tips", Views: 1})
+	index.put(entity.Video{ID: "2", Title: "Effective Gophers", Views: 2})
+	index.put(entity.Video{ID: "3", Title: "Effective Rust"})
+
+	assert.Equal(t, []string{"Effective Gophers", "Effective Go tips"}, suggestedTitles(index.complete("effective go", 10)))
+	assert.Equal(t, []string{"Effective Go tips"}, suggestedTitles(index.complete("effective go ", 10)))
+	assert.Empty(t, index.complete("effective java", 10))
+}
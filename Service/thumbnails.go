@@ -0,0 +1,264 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png" // registers the PNG decoder
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	_ "golang.org/x/image/webp" // registers the WebP decoder
+
+	entity "videoAPI/Entity"
+)
+
+// ThumbnailSizes are the widths, in pixels, posters are resized to. They are
+// set once at startup.
+var ThumbnailSizes = []int{160, 320, 640, 1280}
+
+// MaxPosterBytes bounds the size of an uploaded poster image. It is set once
+// at startup.
+var MaxPosterBytes int64 = 10 << 20
+
+const (
+	// ThumbnailContentType is the type every thumbnail is stored in.
+	ThumbnailContentType = "image/jpeg"
+	thumbnailQuality     = 85
+	// maxPosterPixels keeps a small file that decodes to a huge image from
+	// exhausting memory.
+	maxPosterPixels = 50_000_000
+)
+
+// posterTypes are the image types accepted as posters.
+var posterTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+var (
+	ErrPosterTooLarge     = TooLarge("poster_too_large", "Poster image is larger than the upload limit")
+	ErrThumbnailNotFound  = NotFound("thumbnail_not_found", "Video has no thumbnail")
+	errPosterDimensions   = Validation("poster_dimensions", "Poster image has too many pixels")
+	errPosterUndecodeable = Validation("invalid_image", "Poster image is corrupt")
+)
+
+// decodePoster reads body as a poster image.
+func decodePoster(body io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(&limitedReader{reader: body, remaining: MaxPosterBytes, err: ErrPosterTooLarge})
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, Validation("empty_image", "Poster image is empty")
+	}
+
+	detected := mimetype.Detect(data).String()
+	if !posterTypes[detected] {
+		return nil, Unsupported("unsupported_image", "Uploaded file is "+detected+", not a JPEG, PNG or WebP image")
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, Unsupported("unsupported_image", "Images of type "+detected+" cannot be decoded by this server")
+	}
+	if err != nil {
+		return nil, errPosterUndecodeable
+	}
+	if config.Width < 1 || config.Height < 1 || config.Width*config.Height > maxPosterPixels {
+		return nil, errPosterDimensions
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errPosterUndecodeable
+	}
+	return img, nil
+}
+
+// flattenImage draws src onto white, since thumbnails are stored as JPEG and
+// have no transparency. The result starts at the origin.
+func flattenImage(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), src, bounds.Min, draw.Over)
+	return flat
+}
+
+// scaleImage resizes flat, as returned by flattenImage, to width by height,
+// averaging the source pixels under each destination pixel.
+func scaleImage(flat *image.RGBA, width, height int) *image.RGBA {
+	srcWidth, srcHeight := flat.Bounds().Dx(), flat.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcHeight/height, (y+1)*srcHeight/height
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcWidth/width, (x+1)*srcWidth/width
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, count int
+			for sy := y0; sy < y1; sy++ {
+				row := flat.Pix[sy*flat.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					r += int(row[sx*4])
+					g += int(row[sx*4+1])
+					b += int(row[sx*4+2])
+					count++
+				}
+			}
+
+			i := y*dst.Stride + x*4
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(r/count), uint8(g/count), uint8(b/count), 0xff
+		}
+	}
+	return dst
+}
+
+// thumbnailBounds returns the size of the thumbnail of width pixels of an
+// image, keeping its aspect ratio. Images are never enlarged.
+func thumbnailBounds(bounds image.Rectangle, width int) (int, int) {
+	if width > bounds.Dx() {
+		width = bounds.Dx()
+	}
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	return width, height
+}
+
+func thumbnailKey(poster entity.Poster, size int) string {
+	return poster.Key + "/" + strconv.Itoa(size) + ".jpg"
+}
+
+// UploadPoster resizes the poster image in body to each of ThumbnailSizes,
+// stores the results on the video with the given ID and version, and points
+// its thumbnail URL at url.
+func (service *videoService) UploadPoster(id string, version int64, body io.Reader, url, actor string) (entity.Video, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	img, err := decodePoster(body)
+	if err != nil {
+		return entity.Video{}, err
+	}
+
+	upload, err := newVideoID()
+	if err != nil {
+		return entity.Video{}, Internal(err)
+	}
+	sizes := append([]int{}, ThumbnailSizes...)
+	sort.Ints(sizes)
+	poster := entity.Poster{Key: "videos/" + id + "/thumbnails/" + upload, Sizes: sizes, UploadedAt: time.Now().UTC().Truncate(time.Millisecond)}
+
+	flat := flattenImage(img)
+	for _, size := range sizes {
+		width, height := thumbnailBounds(flat.Bounds(), size)
+		var encoded bytes.Buffer
+		if err := jpeg.Encode(&encoded, scaleImage(flat, width, height), &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+			service.deletePoster(ctx, poster)
+			return entity.Video{}, Internal(err)
+		}
+		if _, err := service.blobs.Put(ctx, thumbnailKey(poster, size), &encoded, int64(encoded.Len()), ThumbnailContentType); err != nil {
+			service.deletePoster(ctx, poster)
+			return entity.Video{}, AsError(err)
+		}
+	}
+
+	video, err := service.attachPoster(ctx, id, version, poster, url, actor)
+	if err != nil {
+		service.deletePoster(ctx, poster)
+		return entity.Video{}, err
+	}
+	return video, nil
+}
+
+// attachPoster records poster on the video and removes the thumbnails it
+// replaces.
+func (service *videoService) attachPoster(ctx context.Context, id string, version int64, poster entity.Poster, url, actor string) (entity.Video, error) {
+	filter := live(bson.M{"id": id, "version": version})
+	update := bson.M{
+		"$set": bson.M{"poster": poster, "thumbnail_url": url, "updated_at": poster.UploadedAt},
+		"$inc": bson.M{"version": 1},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	var previous entity.Video
+	if err := service.videoCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entity.Video{}, service.missOrMismatch(ctx, id)
+		}
+		return entity.Video{}, Internal(err)
+	}
+
+	updated := previous
+	updated.Poster = &poster
+	updated.ThumbnailURL = url
+	updated.UpdatedAt = poster.UploadedAt
+	updated.Version = previous.Version + 1
+
+	service.recordRevision(previous, updated, actor)
+	service.cacheVideo(ctx, updated)
+	if previous.Poster != nil {
+		service.deletePoster(ctx, *previous.Poster)
+	}
+
+	return updated, nil
+}
+
+// OpenThumbnail opens the thumbnail of video that is size pixels wide, or the
+// largest one when size is 0. The caller closes it.
+func (service *videoService) OpenThumbnail(video entity.Video, size int) (io.ReadSeekCloser, BlobInfo, error) {
+	if video.Poster == nil || len(video.Poster.Sizes) == 0 {
+		return nil, BlobInfo{}, ErrThumbnailNotFound
+	}
+	sizes := video.Poster.Sizes
+	if size == 0 {
+		size = sizes[len(sizes)-1]
+	}
+	if index := sort.SearchInts(sizes, size); index == len(sizes) || sizes[index] != size {
+		available := make([]string, len(sizes))
+		for i, s := range sizes {
+			available[i] = strconv.Itoa(s)
+		}
+		return nil, BlobInfo{}, Validation("invalid_size", "Thumbnail size must be one of "+strings.Join(available, ", "))
+	}
+
+	file, info, err := service.blobs.Get(context.Background(), thumbnailKey(*video.Poster, size))
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			return nil, BlobInfo{}, ErrThumbnailNotFound
+		}
+		return nil, BlobInfo{}, err
+	}
+	info.ContentType = ThumbnailContentType
+	if info.ETag == "" {
+		info.ETag = `"` + path.Base(video.Poster.Key) + "-" + strconv.Itoa(size) + `"`
+	}
+	return file, info, nil
+}
+
+func (service *videoService) deletePoster(ctx context.Context, poster entity.Poster) {
+	for _, size := range poster.Sizes {
+		service.deleteBlob(ctx, thumbnailKey(poster, size))
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	entity "videoAPI/Entity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPNG(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, img))
+	return encoded.Bytes()
+}
+
+func TestDecodePoster(t *testing.T) {
+	img, err := decodePoster(bytes.NewReader(testPNG(t, 40, 20)))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 40, 20), img.Bounds())
+
+	_, err = decodePoster(strings.NewReader("just some text"))
+	assert.ErrorIs(t, err, Unsupported("unsupported_image", ""))
+
+	// One lossless pixel
+	webp := []byte("RIFF\x18\x00\x00\x00WEBPVP8L\x0c\x00\x00\x00\x2f\x00\x00\x00\x10\x28\x59\x91\x2b\xd3\xff\x00")
+	img, err = decodePoster(bytes.NewReader(webp))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 1, 1), img.Bounds())
+	assert.Equal(t, color.NRGBA{R: 200, G: 100, B: 50, A: 255}, color.NRGBAModel.Convert(img.At(0, 0)))
+
+	corrupt := testPNG(t, 40, 20)[:60]
+	_, err = decodePoster(bytes.NewReader(corrupt))
+	assert.ErrorIs(t, err, Validation("invalid_image", ""))
+
+	_, err = decodePoster(strings.NewReader(""))
+	assert.ErrorIs(t, err, Validation("empty_image", ""))
+}
+
+func TestScaleImage(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		src.Set(0, y, color.NRGBA{R: 255, A: 255})
+		src.Set(1, y, color.NRGBA{B: 255, A: 255})
+		// right half transparent, flattened onto white
+	}
+
+	scaled := scaleImage(flattenImage(src), 2, 1)
+	assert.Equal(t, color.RGBA{R: 127, B: 127, A: 255}, scaled.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, scaled.RGBAAt(1, 0))
+}
+
+func TestThumbnailBounds(t *testing.T) {
+	bounds := image.Rect(0, 0, 1920, 1080)
+	w, h := thumbnailBounds(bounds, 320)
+	assert.Equal(t, []int{320, 180}, []int{w, h})
+
+	w, h = thumbnailBounds(bounds, 4000)
+	assert.Equal(t, []int{1920, 1080}, []int{w, h}, "never enlarged")
+}
+
+func TestOpenThumbnail(t *testing.T) {
+	service := newUploadService(t)
+	poster := entity.Poster{Key: "videos/v1/thumbnails/p1", Sizes: []int{160, 320}}
+	for _, size := range poster.Sizes {
+		_, err := service.blobs.Put(context.Background(), thumbnailKey(poster, size), bytes.NewReader([]byte{byte(size / 10)}), 1, ThumbnailContentType)
+		require.NoError(t, err)
+	}
+	video := entity.Video{ID: "v1", Poster: &poster}
+
+	file, info, err := service.OpenThumbnail(video, 0)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(file)
+	file.Close()
+	assert.Equal(t, []byte{32}, body, "largest size by default")
+	assert.Equal(t, ThumbnailContentType, info.ContentType)
+	assert.NotEmpty(t, info.ETag)
+
+	file, _, err = service.OpenThumbnail(video, 160)
+	require.NoError(t, err)
+	body, _ = io.ReadAll(file)
+	file.Close()
+	assert.Equal(t, []byte{16}, body)
+
+	_, _, err = service.OpenThumbnail(video, 200)
+	assert.ErrorIs(t, err, Validation("invalid_size", ""))
+
+	_, _, err = service.OpenThumbnail(entity.Video{ID: "v2"}, 0)
+	assert.ErrorIs(t, err, ErrThumbnailNotFound)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	entity "videoAPI/Entity"
+)
+
+// FindTrash lists deleted videos, most recently deleted first.
+func (service *videoService) FindTrash() ([]entity.Video, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"deleted_at": bson.M{"$ne": nil}}
+	findOptions := options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+	cursor, err := service.videoCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	defer cursor.Close(ctx)
+
+	videos := []entity.Video{}
+	if err := cursor.All(ctx, &videos); err != nil {
+		return nil, Internal(err)
+	}
+
+	return videos, nil
+}
+
+// FindDeleted returns a video in the trash.
+func (service *videoService) FindDeleted(id string) (entity.Video, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"id": id, "deleted_at": bson.M{"$ne": nil}}
+	var video entity.Video
+	if err := service.videoCollection.FindOne(ctx, filter).Decode(&video); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entity.Video{}, ErrVideoNotInTrash
+		}
+		return entity.Video{}, Internal(err)
+	}
+	return video, nil
+}
+
+// Restore takes a video out of the trash.
+func (service *videoService) Restore(id string) (entity.Video, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"id": id, "deleted_at": bson.M{"$ne": nil}}
+	update := bson.M{
+		"$unset": bson.M{"deleted_at": "", "deleted_by": ""},
+		"$set":   bson.M{"updated_at": time.Now().UTC()},
+		"$inc":   bson.M{"version": 1},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var restored entity.Video
+	err := service.videoCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&restored)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entity.Video{}, ErrVideoNotInTrash
+		}
+		return entity.Video{}, Internal(err)
+	}
+
+	service.uncacheVideo(ctx, id)
+	service.suggestions.put(restored)
+	service.fuzzy.put(restored)
+
+	return restored, nil
+}
+
+// PurgeDeleted permanently removes videos that have been in the trash for
+// longer than retention, along with their uploaded files, thumbnails and
+// captions, and reports how many were removed.
+func (service *videoService) PurgeDeleted(retention time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cutoff := time.Now().UTC().Add(-retention)
+	filter := bson.M{"deleted_at": bson.M{"$lt": cutoff}}
+
+	withFiles := bson.M{"deleted_at": filter["deleted_at"], "$or": []bson.M{
+		{"media": bson.M{"$ne": nil}},
+		{"poster": bson.M{"$ne": nil}},
+		{"captions.0": bson.M{"$exists": true}},
+	}}
+	projection := bson.M{"id": 1, "media": 1, "poster": 1, "captions": 1}
+	cursor, err := service.videoCollection.Find(ctx, withFiles, options.Find().SetProjection(projection))
+	if err != nil {
+		return 0, Internal(err)
+	}
+	var purged []entity.Video
+	if err := cursor.All(ctx, &purged); err != nil {
+		return 0, Internal(err)
+	}
+
+	result, err := service.videoCollection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, Internal(err)
+	}
+
+	for _, video := range purged {
+		if video.Media != nil {
+			service.deleteBlob(ctx, video.Media.Key)
+		}
+		if video.Poster != nil {
+			service.deletePoster(ctx, *video.Poster)
+		}
+		if len(video.Captions) > 0 {
+			service.deleteCaptions(ctx, video)
+		}
+	}
+
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	entity "videoAPI/Entity"
+)
+
+var (
+	ErrUploadNotFound       = NotFound("upload_not_found", "Upload not found or expired")
+	ErrUploadOffsetMismatch = Conflict("upload_offset_mismatch", "Upload-Offset does not match the bytes received")
+)
+
+// UploadStore keeps the state of resumable uploads. The data itself is in
+// the blob store; an upload only lists its parts.
+type UploadStore interface {
+	Create(upload entity.Upload) error
+	Get(id string) (entity.Upload, error)
+	// AddPart appends part if the upload is still at part.Offset and returns
+	// the upload with it added. Otherwise it fails with
+	// ErrUploadOffsetMismatch.
+	AddPart(id string, part entity.UploadPart) (entity.Upload, error)
+	// Complete marks the upload completed and drops its parts, returning
+	// false if it already was.
+	Complete(id string, at time.Time) (bool, error)
+	Delete(id string) error
+	// Expired lists uploads whose expiry is before t.
+	Expired(t time.Time) ([]entity.Upload, error)
+}
+
+type mongoUploadStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoUploadStore(collection *mongo.Collection) UploadStore {
+	return &mongoUploadStore{collection: collection}
+}
+
+func (store *mongoUploadStore) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := store.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}},
+	})
+	return err
+}
+
+func (store *mongoUploadStore) Create(upload entity.Upload) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := store.collection.InsertOne(ctx, upload)
+	return err
+}
+
+func (store *mongoUploadStore) Get(id string) (entity.Upload, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var upload entity.Upload
+	err := store.collection.FindOne(ctx, bson.M{"id": id}).Decode(&upload)
+	if err == mongo.ErrNoDocuments {
+		return entity.Upload{}, ErrUploadNotFound
+	}
+	return upload, err
+}
+
+func (store *mongoUploadStore) AddPart(id string, part entity.UploadPart) (entity.Upload, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"id": id, "offset": part.Offset, "completed_at": nil}
+	update := bson.M{
+		"$push": bson.M{"parts": part},
+		"$inc":  bson.M{"offset": part.Size},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var upload entity.Upload
+	err := store.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&upload)
+	if err == mongo.ErrNoDocuments {
+		if _, err := store.Get(id); err != nil {
+			return entity.Upload{}, err
+		}
+		return entity.Upload{}, ErrUploadOffsetMismatch
+	}
+	return upload, err
+}
+
+func (store *mongoUploadStore) Complete(id string, at time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"id": id, "completed_at": nil}
+	update := bson.M{"$set": bson.M{"completed_at": at, "parts": []entity.UploadPart{}}}
+	result, err := store.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (store *mongoUploadStore) Delete(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := store.collection.DeleteOne(ctx, bson.M{"id": id})
+	return err
+}
+
+func (store *mongoUploadStore) Expired(t time.Time) ([]entity.Upload, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cursor, err := store.collection.Find(ctx, bson.M{"expires_at": bson.M{"$lt": t}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	uploads := []entity.Upload{}
+	if err := cursor.All(ctx, &uploads); err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
+
+type memoryUploadStore struct {
+	mu      sync.Mutex
+	uploads map[string]entity.Upload
+}
+
+// NewMemoryUploadStore keeps uploads in process memory, for tests and
+// single-instance deployments without Mongo. Offsets do not survive a
+// restart.
+func NewMemoryUploadStore() UploadStore {
+	return &memoryUploadStore{uploads: map[string]entity.Upload{}}
+}
+
+func (store *memoryUploadStore) Create(upload entity.Upload) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.uploads[upload.ID] = upload
+	return nil
+}
+
+func (store *memoryUploadStore) Get(id string) (entity.Upload, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	upload, ok := store.uploads[id]
+	if !ok {
+		return entity.Upload{}, ErrUploadNotFound
+	}
+	return upload, nil
+}
+
+func (store *memoryUploadStore) AddPart(id string, part entity.UploadPart) (entity.Upload, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	upload, ok := store.uploads[id]
+	if !ok {
+		return entity.Upload{}, ErrUploadNotFound
+	}
+	if upload.Offset != part.Offset || upload.CompletedAt != nil {
+		return entity.Upload{}, ErrUploadOffsetMismatch
+	}
+	upload.Parts = append(append([]entity.UploadPart{}, upload.Parts...), part)
+	upload.Offset += part.Size
+	store.uploads[id] = upload
+	return upload, nil
+}
+
+func (store *memoryUploadStore) Complete(id string, at time.Time) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	upload, ok := store.uploads[id]
+	if !ok || upload.CompletedAt != nil {
+		return false, nil
+	}
+	upload.CompletedAt = &at
+	upload.Parts = []entity.UploadPart{}
+	store.uploads[id] = upload
+	return true, nil
+}
+
+func (store *memoryUploadStore) Delete(id string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.uploads, id)
+	return nil
+}
+
+func (store *memoryUploadStore) Expired(t time.Time) ([]entity.Upload, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	uploads := []entity.Upload{}
+	for _, upload := range store.uploads {
+		if upload.ExpiresAt.Before(t) {
+			uploads = append(uploads, upload)
+		}
+	}
+	return uploads, nil
+}
@@ -3,12 +3,12 @@ package service
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"strconv"
+	"io"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -19,16 +19,45 @@ import (
 
 type VideoService interface {
 	Save(entity.Video) (entity.Video, error)
-	Delete(string) error
+	Delete(string, int64, string) error
 	FindAll() ([]entity.Video, error)
 	FindByID(string) (entity.Video, error)
 	VideoExists(string) bool
-	Update(*entity.Video, map[string]string) error
-	SearchAndPaginate(string, string, int) ([]entity.Video, error)
+	Update(*entity.Video, string) error
+	SearchAndPaginate(SearchRequest) (entity.SearchPage, error)
+	RecordView(string) error
+	Suggest(string, int) ([]entity.Suggestion, error)
+	UploadMedia(string, int64, io.Reader, string, string) (entity.Video, error)
+	OpenMedia(string) (io.ReadSeekCloser, entity.Video, error)
+	CreateUpload(string, int64, map[string]string, string) (entity.Upload, error)
+	FindUpload(string, string) (entity.Upload, error)
+	WriteUpload(string, string, int64, io.Reader, string) (entity.Upload, error)
+	DeleteUpload(string, string) error
+	ExpireUploads() (int64, error)
+	PutRendition(string, int64, entity.Rendition) (entity.Video, error)
+	OpenSegment(entity.Video, string, string) (io.ReadSeekCloser, BlobInfo, error)
+	UploadPoster(string, int64, io.Reader, string, string) (entity.Video, error)
+	OpenThumbnail(entity.Video, int) (io.ReadSeekCloser, BlobInfo, error)
+	PutCaptions(string, int64, entity.CaptionTrack, io.Reader) (entity.Video, error)
+	DeleteCaptions(string, int64, string) (entity.Video, error)
+	OpenCaptions(entity.Video, string) (io.ReadSeekCloser, BlobInfo, error)
+	SearchCaptions(string, int) ([]entity.CaptionHit, error)
+	PutChapters(string, int64, []entity.Chapter) (entity.Video, error)
+	FindTrash() ([]entity.Video, error)
+	FindDeleted(id string) (entity.Video, error)
+	Restore(string) (entity.Video, error)
+	PurgeDeleted(time.Duration) (int64, error)
+	CheckLinks(context.Context, *LinkChecker, time.Duration, int) (int, int, error)
+	FindRevisions(string) ([]entity.Revision, error)
+	RevertToRevision(string, int64, int64, string) (entity.Video, error)
+	Migrate() error
+	EnsureIndexes() error
+	LoadSearchIndexes() error
 
 	//Authorization
 	CreateUser(user entity.User) error
 	GetUserByEmail(email string) (User, error)
+	SetUserRole(email, role string) (User, error)
 }
 
 type videoService struct {
@@ -36,14 +65,25 @@ type videoService struct {
 	videoCollection *mongo.Collection
 	userCollection  *mongo.Collection
 	redis           *redis.Client
+	revisions       RevisionStore
+	suggestions     *suggestIndex
+	fuzzy           *fuzzyIndex
+	blobs           BlobStore
+	uploads         UploadStore
+	captions        CaptionStore
 }
 
 type User struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Role     string `json:"role" bson:"role,omitempty"`
 }
 
-func NewMongoVideoService(client *mongo.Client, dbName, videoCollectionName string, userCollectionName string, redisClient *redis.Client) VideoService {
+// RoleAdmin is granted by an admin through SetUserRole. Users without a role
+// are regular users.
+const RoleAdmin = "admin"
+
+func NewMongoVideoService(client *mongo.Client, dbName, videoCollectionName string, userCollectionName string, redisClient *redis.Client, revisions RevisionStore, blobs BlobStore, uploads UploadStore, captions CaptionStore) VideoService {
 	videoCollection := client.Database(dbName).Collection(videoCollectionName)
 	userCollection := client.Database(dbName).Collection(userCollectionName)
 	return &videoService{
@@ -51,6 +91,12 @@ func NewMongoVideoService(client *mongo.Client, dbName, videoCollectionName stri
 		videoCollection: videoCollection,
 		userCollection:  userCollection,
 		redis:           redisClient,
+		revisions:       revisions,
+		suggestions:     newSuggestIndex(),
+		fuzzy:           newFuzzyIndex(),
+		blobs:           blobs,
+		uploads:         uploads,
+		captions:        captions,
 	}
 }
 
@@ -58,59 +104,212 @@ func (service *videoService) Save(newVideo entity.Video) (entity.Video, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := service.videoCollection.InsertOne(ctx, newVideo)
+	id, err := newVideoID()
 	if err != nil {
-		return entity.Video{}, err
+		return entity.Video{}, Internal(err)
+	}
+	newVideo.ID = id
+
+	now := time.Now().UTC()
+	newVideo.CreatedAt = now
+	newVideo.UpdatedAt = now
+	newVideo.SchemaVersion = currentSchemaVersion
+	newVideo.Version = 1
+	newVideo.Views = 0
+	newVideo.DeletedAt = nil
+	newVideo.DeletedBy = ""
+	newVideo.Media = nil
+	newVideo.Renditions = nil
+	newVideo.MediaInfo = entity.MediaInfo{}
+	newVideo.Poster = nil
+	newVideo.Captions = nil
+	newVideo.Chapters = nil
+	newVideo.LinkStatus = nil
+	if newVideo.Visibility == "" {
+		newVideo.Visibility = entity.VisibilityPublic
+	}
+	if newVideo.Tags == nil {
+		newVideo.Tags = []string{}
+	}
+
+	_, err = service.videoCollection.InsertOne(ctx, newVideo)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return entity.Video{}, ErrVideoExists
+		}
+		return entity.Video{}, Internal(err)
 	}
 
+	service.recordRevision(entity.Video{}, newVideo, newVideo.Owner)
+	service.suggestions.put(newVideo)
+	service.fuzzy.put(newVideo)
+
 	return newVideo, nil
 }
 
-func (service *videoService) Delete(id string) error {
+// newVideoID returns a time-ordered UUIDv7, so new videos sort after old ones
+// in the unique id index.
+func newVideoID() (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// Delete moves the video to the trash if it is still at the given version.
+// It stays there, hidden from every lookup but FindTrash, until it is
+// restored or purged.
+func (service *videoService) Delete(id string, version int64, actor string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	filter := bson.M{"id": id}
-	_, err := service.videoCollection.DeleteOne(ctx, filter)
+	filter := live(bson.M{"id": id, "version": version})
+	update := bson.M{
+		"$set": bson.M{"deleted_at": time.Now().UTC(), "deleted_by": actor},
+		"$inc": bson.M{"version": 1},
+	}
+	result, err := service.videoCollection.UpdateOne(ctx, filter, update)
 	if err != nil {
-		return err
+		return Internal(err)
+	}
+	if result.MatchedCount == 0 {
+		return service.missOrMismatch(ctx, id)
 	}
 
 	// Update the cache after successful deletion
-	cacheKey := "video:" + id
-	err = service.redis.Del(ctx, cacheKey).Err()
-	if err != nil {
-		fmt.Printf("Error deleting cached video from Redis: %v", err)
-	}
+	service.uncacheVideo(ctx, id)
+	service.suggestions.delete(id)
+	service.fuzzy.delete(id)
 
 	return nil
 }
 
-func (service *videoService) Update(existingVideo *entity.Video, updateFields map[string]string) error {
+// Update stores the client-editable fields of existingVideo if the stored
+// video is still at existingVideo.Version, bumping the version and recording
+// a revision by actor. On success existingVideo is replaced with the stored
+// result.
+func (service *videoService) Update(existingVideo *entity.Video, actor string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	update := bson.M{}
-	for key, value := range updateFields {
-		update[key] = value
+	tags := existingVideo.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	update := bson.M{
+		"title":         existingVideo.Title,
+		"description":   existingVideo.Description,
+		"url":           existingVideo.URL,
+		"tags":          tags,
+		"category":      existingVideo.Category,
+		"duration":      existingVideo.Duration,
+		"language":      existingVideo.Language,
+		"thumbnail_url": existingVideo.ThumbnailURL,
+		"visibility":    existingVideo.Visibility,
+		"updated_at":    time.Now().UTC().Truncate(time.Millisecond),
+	}
+	if existingVideo.Visibility == "" {
+		update["visibility"] = entity.VisibilityPublic
+	}
+
+	filter := live(bson.M{"id": existingVideo.ID, "version": existingVideo.Version})
+	updateDoc := bson.M{"$set": update, "$inc": bson.M{"version": 1}}
+
+	// The previous document is returned so the revision can be diffed; the
+	// stored result is that document with the update applied.
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	var previous entity.Video
+	err := service.videoCollection.FindOneAndUpdate(ctx, filter, updateDoc, opts).Decode(&previous)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return service.missOrMismatch(ctx, existingVideo.ID)
+		}
+		return Internal(err)
 	}
 
-	filter := bson.M{"id": existingVideo.ID}
-	updateDoc := bson.M{"$set": update}
+	updated := previous
+	updated.Title = existingVideo.Title
+	updated.Description = existingVideo.Description
+	updated.URL = existingVideo.URL
+	updated.Tags = update["tags"].([]string)
+	updated.Category = existingVideo.Category
+	updated.Duration = existingVideo.Duration
+	updated.Language = existingVideo.Language
+	updated.ThumbnailURL = existingVideo.ThumbnailURL
+	updated.Visibility = update["visibility"].(string)
+	updated.UpdatedAt = update["updated_at"].(time.Time)
+	updated.Version = previous.Version + 1
+	*existingVideo = updated
+
+	service.recordRevision(previous, updated, actor)
+
+	// Replace the cached individual video with the new version
+	service.cacheVideo(ctx, updated)
+	service.suggestions.put(updated)
+	service.fuzzy.put(updated)
 
-	_, err := service.videoCollection.UpdateOne(ctx, filter, updateDoc)
+	return nil
+}
+
+// missOrMismatch explains why a write conditioned on id and version matched
+// nothing: either the video is gone or it has moved to another version.
+func (service *videoService) missOrMismatch(ctx context.Context, id string) error {
+	count, err := service.videoCollection.CountDocuments(ctx, live(bson.M{"id": id}))
 	if err != nil {
-		return err
+		return Internal(err)
+	}
+	if count == 0 {
+		return ErrVideoNotFound
+	}
+	return ErrVersionMismatch
+}
+
+// setServerFields sets server-owned fields of the video with the given ID
+// and version, as of at, and returns it updated.
+func (service *videoService) setServerFields(ctx context.Context, id string, version int64, set bson.M, at time.Time) (entity.Video, error) {
+	set["updated_at"] = at
+	filter := live(bson.M{"id": id, "version": version})
+	update := bson.M{
+		"$set": set,
+		"$inc": bson.M{"version": 1},
 	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 
-	// Remove the cached individual video
-	cacheKey := "video:" + existingVideo.ID
-	err = service.redis.Del(ctx, cacheKey).Err()
+	var updated entity.Video
+	if err := service.videoCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entity.Video{}, service.missOrMismatch(ctx, id)
+		}
+		return entity.Video{}, Internal(err)
+	}
+
+	service.cacheVideo(ctx, updated)
+	return updated, nil
+}
+
+// live restricts filter to videos that are not in the trash.
+func live(filter bson.M) bson.M {
+	filter["deleted_at"] = nil
+	return filter
+}
+
+// uncacheVideo drops a video, and the listing that may contain it, from the
+// cache.
+func (service *videoService) uncacheVideo(ctx context.Context, id string) {
+	err := service.redis.Del(ctx, "video:"+id, "videos").Err()
 	if err != nil {
 		fmt.Printf("Error deleting cached video from Redis: %v", err)
 	}
+}
 
-	return nil
+// cacheVideo stores video, including its version, under its cache key.
+func (service *videoService) cacheVideo(ctx context.Context, video entity.Video) {
+	jsonVideo, _ := json.Marshal(video)
+	err := service.redis.Set(ctx, "video:"+video.ID, jsonVideo, 5*time.Minute).Err()
+	if err != nil {
+		fmt.Printf("Error caching video in Redis: %v", err)
+	}
 }
 
 func (service *videoService) FindAll() ([]entity.Video, error) {
@@ -127,15 +326,15 @@ func (service *videoService) FindAll() ([]entity.Video, error) {
 	}
 
 	//Retrieved from database
-	cursor, err := service.videoCollection.Find(ctx, bson.M{})
+	cursor, err := service.videoCollection.Find(ctx, live(bson.M{}))
 	if err != nil {
-		return nil, err
+		return nil, Internal(err)
 	}
 	defer cursor.Close(ctx)
 
 	var videos []entity.Video
 	if err := cursor.All(ctx, &videos); err != nil {
-		return nil, err
+		return nil, Internal(err)
 	}
 
 	//Store data in the cache
@@ -164,25 +363,37 @@ func (service *videoService) FindByID(id string) (entity.Video, error) {
 	}
 
 	//If not in the cache, retrieve from database
-	filter := bson.M{"id": id}
+	filter := live(bson.M{"id": id})
 	var video entity.Video
 	if err := service.videoCollection.FindOne(ctx, filter).Decode(&video); err != nil {
 		if err == mongo.ErrNoDocuments {
-			return entity.Video{}, errors.New("Video not found")
+			return entity.Video{}, ErrVideoNotFound
 		}
-		return entity.Video{}, err
+		return entity.Video{}, Internal(err)
 	}
 
 	//Store retrieved video in cache
-	jsonVideo, _ := json.Marshal(video)
-	err = service.redis.Set(ctx, cacheKey, jsonVideo, 5*time.Minute).Err()
-	if err != nil {
-		fmt.Printf("Error caching video in Redis: %v", err)
-	}
+	service.cacheVideo(ctx, video)
 
 	return video, nil
 }
 
+// RecordView counts a view of the video. Views are not a revision of the
+// video, so neither the version nor the cached copy changes.
+func (service *videoService) RecordView(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := service.videoCollection.UpdateOne(ctx, live(bson.M{"id": id}), bson.M{"$inc": bson.M{"views": 1}})
+	if err != nil {
+		return Internal(err)
+	}
+	if result.MatchedCount > 0 {
+		service.suggestions.viewed(id)
+	}
+	return nil
+}
+
 func (service *videoService) VideoExists(id string) bool {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -197,44 +408,129 @@ func (service *videoService) VideoExists(id string) bool {
 	return count > 0
 }
 
-func (service *videoService) SearchAndPaginate(page string, query string, perPage int) ([]entity.Video, error) {
-	// Define the MongoDB query based on the query parameter
-	pageNum, err := strconv.Atoi(page)
+// SearchAndPaginate returns one page of videos matching request.Query and
+// request.Filter. Unless request.Sort says otherwise, text searches are most
+// relevant first and other listings oldest first. See parseSearchQuery for
+// the query syntax and parseSort for the sort syntax.
+func (service *videoService) SearchAndPaginate(request SearchRequest) (entity.SearchPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if request.PerPage < 1 || request.PerPage > MaxPerPage {
+		return entity.SearchPage{}, Validation("invalid_per_page", fmt.Sprintf("per_page must be between 1 and %d", MaxPerPage))
+	}
+	if request.Cursor == "" && (request.Page < 1 || request.Page > MaxPage) {
+		return entity.SearchPage{}, Validation("invalid_page", fmt.Sprintf("Page must be between 1 and %d; use cursor to read further", MaxPage))
+	}
+
+	spec, err := parseSort(request.Sort)
 	if err != nil {
-		return nil, err
+		return entity.SearchPage{}, err
 	}
 
-	filter := bson.M{}
-	if query != "" {
-		filter["$or"] = []bson.M{
-			{"title": bson.M{"$regex": query, "$options": "i"}},
-			{"url": bson.M{"$regex": query, "$options": "i"}},
+	search := parseSearchQuery(request.Query)
+	fuzzy := service.fuzzyMatch(request)
+
+	var filter bson.M
+	if fuzzy != nil {
+		filter = request.Filter.mongo(live(bson.M{"id": bson.M{"$in": fuzzy.ids()}}))
+		// Highlight the words that matched rather than the misspelled ones
+		search = searchQuery{Terms: fuzzy.Words}
+	} else {
+		filter = request.Filter.mongo(search.filter(live(bson.M{})))
+	}
+	textScored := fuzzy == nil && search.usesTextIndex()
+	byRelevance := (fuzzy != nil || textScored) && spec.Field == ""
+
+	total, err := service.videoCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return entity.SearchPage{}, Internal(err)
+	}
+
+	var facets *entity.Facets
+	if request.Facets {
+		facets, err = service.facets(ctx, filter, facetCacheKey(search, request.Filter, fuzzy != nil))
+		if err != nil {
+			return entity.SearchPage{}, err
 		}
 	}
 
-	skip := (pageNum - 1) * perPage
+	// One extra item tells whether there is a next page
+	findOptions := options.Find().SetLimit(int64(request.PerPage + 1))
+	if textScored {
+		score := bson.M{"$meta": "textScore"}
+		findOptions.SetProjection(bson.M{"score": score})
+		if byRelevance {
+			findOptions.SetSort(bson.D{{Key: "score", Value: score}, {Key: "id", Value: 1}})
+		}
+	}
+	if !byRelevance {
+		findOptions.SetSort(spec.mongoSort())
+	}
+	// Fuzzy scores only exist here, so every match is fetched and the page
+	// cut out after ranking; fuzzyMatches.ids bounds how many there are.
+	rankFuzzy := fuzzy != nil && byRelevance
+	skip := (request.Page - 1) * request.PerPage
+	if rankFuzzy {
+		findOptions.SetLimit(0)
+	}
+
+	if request.Cursor != "" {
+		if byRelevance {
+			return entity.SearchPage{}, Validation("cursor_unsupported", "Relevance-ranked searches are paged by page number")
+		}
+		cursor, err := decodeCursor(request.Cursor, spec.String())
+		if err != nil {
+			return entity.SearchPage{}, err
+		}
+		after, err := spec.after(cursor)
+		if err != nil {
+			return entity.SearchPage{}, err
+		}
+		filter = bson.M{"$and": []bson.M{filter, after}}
+		request.Page = 0
+	} else if !rankFuzzy {
+		findOptions.SetSkip(int64(skip))
+	}
 
-	findOptions := options.Find().SetSkip(int64(skip)).SetLimit(int64(perPage))
-	cur, err := service.videoCollection.Find(context.TODO(), filter, findOptions)
+	cur, err := service.videoCollection.Find(ctx, filter, findOptions)
 	if err != nil {
-		return nil, err
+		return entity.SearchPage{}, Internal(err)
 	}
-	defer cur.Close(context.TODO())
+	defer cur.Close(ctx)
 
-	var videos []entity.Video
-	for cur.Next(context.TODO()) {
-		var video entity.Video
-		if err := cur.Decode(&video); err != nil {
-			return nil, err
+	results := []entity.SearchResult{}
+	for cur.Next(ctx) {
+		var result entity.SearchResult
+		if err := cur.Decode(&result); err != nil {
+			return entity.SearchPage{}, Internal(err)
 		}
-		videos = append(videos, video)
+		if fuzzy != nil {
+			result.Score = fuzzy.Scores[result.ID]
+		}
+		result.Highlights = search.highlight(result.Video)
+		results = append(results, result)
 	}
 
 	if err := cur.Err(); err != nil {
-		return nil, err
+		return entity.SearchPage{}, Internal(err)
 	}
 
-	return videos, nil
+	if rankFuzzy {
+		results = fuzzyPage(results, skip, request.PerPage+1)
+	}
+
+	page := entity.SearchPage{Total: total, Page: request.Page, PerPage: request.PerPage, Facets: facets}
+	if len(results) > request.PerPage {
+		results = results[:request.PerPage]
+		page.HasMore = true
+		if !byRelevance {
+			page.NextCursor = encodeCursor(spec.cursorFor(results[len(results)-1].Video))
+		}
+	}
+	page.Items = results
+
+	return page, nil
 }
 
 func (service *videoService) CreateUser(user entity.User) error {
@@ -246,7 +542,7 @@ func (service *videoService) CreateUser(user entity.User) error {
 	}
 
 	if err != nil {
-		return err
+		return Internal(err)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -255,7 +551,10 @@ func (service *videoService) CreateUser(user entity.User) error {
 	_, err = service.userCollection.InsertOne(ctx, hashUser)
 
 	if err != nil {
-		return err
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrUserExists
+		}
+		return Internal(err)
 	}
 
 	return nil
@@ -266,7 +565,37 @@ func (service *videoService) GetUserByEmail(email string) (User, error) {
 	filter := bson.M{"email": email}
 	err := service.userCollection.FindOne(context.TODO(), filter).Decode(&user)
 	if err != nil {
-		return User{}, err
+		if err == mongo.ErrNoDocuments {
+			return User{}, ErrUserNotFound
+		}
+		return User{}, Internal(err)
 	}
 	return user, nil
 }
+
+// SetUserRole gives the user a role, "" making them a regular user, and
+// returns the user as they were before.
+func (service *videoService) SetUserRole(email, role string) (User, error) {
+	if role != "" && role != RoleAdmin {
+		return User{}, ErrInvalidRole
+	}
+
+	update := bson.M{"$set": bson.M{"role": role}}
+	if role == "" {
+		update = bson.M{"$unset": bson.M{"role": ""}}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var previous User
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	err := service.userCollection.FindOneAndUpdate(ctx, bson.M{"email": email}, update, opts).Decode(&previous)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return User{}, ErrUserNotFound
+		}
+		return User{}, Internal(err)
+	}
+	return previous, nil
+}
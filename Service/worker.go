@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	entity "videoAPI/Entity"
+)
+
+// JobHandler does the work of a job. Returning an error, or panicking, fails
+// the attempt. ctx is cancelled when the visibility timeout of the attempt
+// runs out, as the job is then handed to another worker.
+type JobHandler func(ctx context.Context, job entity.Job) error
+
+// WorkerPool runs jobs from a queue with the handlers registered for their
+// types. Failed attempts are retried after an exponential backoff with
+// jitter.
+type WorkerPool struct {
+	Queue        JobQueue
+	Concurrency  int
+	Visibility   time.Duration
+	PollInterval time.Duration
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+
+	handlers map[string]JobHandler
+}
+
+func NewWorkerPool(queue JobQueue) *WorkerPool {
+	return &WorkerPool{
+		Queue:        queue,
+		Concurrency:  4,
+		Visibility:   5 * time.Minute,
+		PollInterval: time.Second,
+		BaseBackoff:  10 * time.Second,
+		MaxBackoff:   time.Hour,
+		handlers:     map[string]JobHandler{},
+	}
+}
+
+// Handle registers the handler of jobType. It is not safe to call once the
+// pool runs.
+func (pool *WorkerPool) Handle(jobType string, handler JobHandler) {
+	pool.handlers[jobType] = handler
+}
+
+// Run works through jobs with Concurrency workers until ctx is cancelled,
+// then waits for the attempts in progress to finish.
+func (pool *WorkerPool) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < pool.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if pool.RunOne() {
+					continue
+				}
+				select {
+				case <-ctx.Done():
+				case <-time.After(pool.PollInterval):
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// RunOne runs the next ready job, if any, and reports whether there was one.
+func (pool *WorkerPool) RunOne() bool {
+	job, ok, err := pool.Queue.Reserve(time.Now().UTC(), pool.Visibility)
+	if err != nil {
+		fmt.Printf("Error reserving job: %v\n", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	// An attempt in progress when ctx is cancelled still gets its full
+	// visibility timeout to finish.
+	attemptCtx, cancel := context.WithTimeout(context.Background(), pool.Visibility)
+	err = pool.run(attemptCtx, job)
+	cancel()
+
+	if err == nil {
+		err = pool.Queue.Complete(job)
+	} else {
+		reason := err.Error()
+		err = pool.Queue.Fail(job, reason, time.Now().UTC().Add(pool.backoff(job.Attempts)))
+		if err == nil && job.Attempts >= job.MaxAttempts {
+			fmt.Printf("Job %s (%s) is dead after %d attempts: %s\n", job.ID, job.Type, job.Attempts, reason)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error recording outcome of job %s (%s): %v\n", job.ID, job.Type, err)
+	}
+	return true
+}
+
+func (pool *WorkerPool) run(ctx context.Context, job entity.Job) (err error) {
+	handler, ok := pool.handlers[job.Type]
+	if !ok {
+		return errors.New("no handler for job type " + job.Type)
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// backoff is how long to wait before retrying a job that has failed
+// attempts times: BaseBackoff doubled for each failure after the first, up to
+// MaxBackoff, less up to a fifth at random so that jobs failing together do
+// not retry together.
+func (pool *WorkerPool) backoff(attempts int) time.Duration {
+	delay := pool.BaseBackoff
+	for i := 1; i < attempts && delay < pool.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > pool.MaxBackoff {
+		delay = pool.MaxBackoff
+	}
+	return delay - time.Duration(rand.Int63n(int64(delay)/5+1))
+}
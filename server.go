@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"io"
-	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	//"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
@@ -16,6 +21,7 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 
 	controller "videoAPI/Controller"
+	entity "videoAPI/Entity"
 	middlewares "videoAPI/Middlewares"
 	service "videoAPI/Service"
 	_ "videoAPI/docs"
@@ -32,6 +38,188 @@ func setupLogOutput() {
 	gin.DefaultWriter = io.MultiWriter(f, os.Stdout)
 }
 
+// envInt reads an integer setting from the environment, falling back to def
+// when it is unset or malformed.
+func envInt(name string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// envInts reads a comma-separated list of positive numbers, such as
+// "160,320,640".
+func envInts(name string, def []int) []int {
+	var values []int
+	for _, field := range strings.Split(os.Getenv(name), ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || value < 1 {
+			return def
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func setupLimits() {
+	entity.Limits = entity.VideoLimits{
+		TitleMin:       envInt("VIDEO_TITLE_MIN", entity.Limits.TitleMin),
+		TitleMax:       envInt("VIDEO_TITLE_MAX", entity.Limits.TitleMax),
+		DescriptionMax: envInt("VIDEO_DESCRIPTION_MAX", entity.Limits.DescriptionMax),
+		MaxTags:        envInt("VIDEO_MAX_TAGS", entity.Limits.MaxTags),
+		TagMax:         envInt("VIDEO_TAG_MAX", entity.Limits.TagMax),
+	}
+}
+
+// envDuration reads a duration such as "720h" from the environment, falling
+// back to def when it is unset or malformed.
+func envDuration(name string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// Job types run by the worker pool.
+const (
+	jobPurgeTrash    = "purge_trash"
+	jobExpireUploads = "expire_uploads"
+	jobCheckLinks    = "check_links"
+)
+
+// setupJobs registers the handlers of background jobs and schedules the
+// recurring ones: purging videos that have been in the trash for longer than
+// TRASH_RETENTION every TRASH_PURGE_INTERVAL, and removing resumable uploads
+// older than UPLOAD_EXPIRY every UPLOAD_EXPIRY_INTERVAL. Every
+// LINK_CHECK_INTERVAL the URLs of up to LINK_CHECK_BATCH videos not checked
+// for LINK_CHECK_MAX_AGE are probed, LINK_CHECK_CONCURRENCY at a time with a
+// LINK_CHECK_TIMEOUT each; LINK_CHECK_ALLOW_PRIVATE=true lets them reach
+// private addresses. The pool runs JOB_WORKERS jobs at a time, each for up to
+// JOB_VISIBILITY_TIMEOUT.
+func setupJobs(queue service.JobQueue) *service.WorkerPool {
+	retention := envDuration("TRASH_RETENTION", 30*24*time.Hour)
+	service.UploadExpiry = envDuration("UPLOAD_EXPIRY", service.UploadExpiry)
+	linkChecker := service.NewLinkChecker(
+		envDuration("LINK_CHECK_TIMEOUT", service.DefaultLinkTimeout),
+		envInt("LINK_CHECK_CONCURRENCY", service.DefaultLinkConcurrency),
+		os.Getenv("LINK_CHECK_ALLOW_PRIVATE") == "true",
+	)
+	linkMaxAge := envDuration("LINK_CHECK_MAX_AGE", 24*time.Hour)
+	linkBatch := envInt("LINK_CHECK_BATCH", 500)
+
+	pool := service.NewWorkerPool(queue)
+	pool.Concurrency = envInt("JOB_WORKERS", pool.Concurrency)
+	pool.Visibility = envDuration("JOB_VISIBILITY_TIMEOUT", pool.Visibility)
+
+	pool.Handle(jobPurgeTrash, func(ctx context.Context, job entity.Job) error {
+		purged, err := videoService.PurgeDeleted(retention)
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			fmt.Printf("Purged %d videos from trash\n", purged)
+		}
+		return nil
+	})
+	pool.Handle(jobExpireUploads, func(ctx context.Context, job entity.Job) error {
+		expired, err := videoService.ExpireUploads()
+		if err != nil {
+			return err
+		}
+		if expired > 0 {
+			fmt.Printf("Expired %d uploads\n", expired)
+		}
+		return nil
+	})
+	pool.Handle(jobCheckLinks, func(ctx context.Context, job entity.Job) error {
+		checked, dead, err := videoService.CheckLinks(ctx, linkChecker, linkMaxAge, linkBatch)
+		if err != nil {
+			return err
+		}
+		if checked > 0 {
+			fmt.Printf("Checked %d video links, %d dead\n", checked, dead)
+		}
+		return nil
+	})
+
+	schedule(queue, jobPurgeTrash, envDuration("TRASH_PURGE_INTERVAL", time.Hour))
+	schedule(queue, jobExpireUploads, envDuration("UPLOAD_EXPIRY_INTERVAL", time.Hour))
+	schedule(queue, jobCheckLinks, envDuration("LINK_CHECK_INTERVAL", time.Hour))
+	return pool
+}
+
+// schedule enqueues a job of jobType at the start of every period of
+// interval, counted on the wall clock so that all instances agree on the
+// periods. The job of a period has the same ID everywhere and is enqueued
+// once, even by an instance that comes to it after the job has completed.
+func schedule(queue service.JobQueue, jobType string, interval time.Duration) {
+	go func() {
+		for {
+			period := time.Now().Truncate(interval).Add(interval)
+			time.Sleep(time.Until(period))
+
+			job, err := service.NewJob(jobType, nil)
+			if err != nil {
+				fmt.Printf("Error scheduling %s: %v\n", jobType, err)
+				continue
+			}
+			job.ID = jobType + ":" + strconv.FormatInt(period.Unix(), 10)
+			if _, err := queue.EnqueueOnce(job, interval); err != nil {
+				fmt.Printf("Error scheduling %s: %v\n", jobType, err)
+			}
+		}
+	}()
+}
+
+// setupAudit writes audit events to Mongo and, when AUDIT_FILE is set, also
+// appends them to that file as JSON lines.
+func setupAudit(client *mongo.Client) (service.AuditLog, error) {
+	mongoSink := service.NewMongoAuditSink(client.Database("trungdb").Collection("auditcl"))
+	if indexed, ok := mongoSink.(interface{ EnsureIndexes() error }); ok {
+		if err := indexed.EnsureIndexes(); err != nil {
+			return nil, err
+		}
+	}
+	sinks := []service.AuditSink{mongoSink}
+
+	if path := os.Getenv("AUDIT_FILE"); path != "" {
+		fileSink, err := service.NewFileAuditSink(path)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, fileSink)
+	}
+
+	return service.NewAuditLog(sinks...), nil
+}
+
+// setupBlobStore keeps uploaded files, thumbnails and captions under BLOB_DIR,
+// or in the S3 bucket named by S3_BUCKET when BLOB_STORE is "s3".
+func setupBlobStore() (service.BlobStore, error) {
+	service.MaxMediaBytes = int64(envInt("MEDIA_MAX_BYTES", int(service.MaxMediaBytes)))
+	service.MaxPosterBytes = int64(envInt("POSTER_MAX_BYTES", int(service.MaxPosterBytes)))
+	service.MaxCaptionBytes = int64(envInt("CAPTION_MAX_BYTES", int(service.MaxCaptionBytes)))
+	service.ThumbnailSizes = envInts("THUMBNAIL_SIZES", service.ThumbnailSizes)
+
+	if os.Getenv("BLOB_STORE") == "s3" {
+		return service.NewS3BlobStore(service.S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		}), nil
+	}
+
+	dir := os.Getenv("BLOB_DIR")
+	if dir == "" {
+		dir = "media"
+	}
+	return service.NewLocalBlobStore(dir)
+}
+
 func setupMongoDB() (*mongo.Client, error) {
 	uri := "mongodb://localhost:27017"
 	clientOptions := options.Client().ApplyURI(uri)
@@ -58,67 +246,108 @@ func setupRedis() (*redis.Client, error) {
 	return redisClient, nil
 }
 
+// handle adapts a controller action to a gin handler. Errors returned by the
+// action are attached to the context and rendered by middlewares.ErrorHandler.
+func handle(action func(controller.VideoController, *gin.Context) error) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if err := action(VideoController, context); err != nil {
+			context.Error(err)
+		}
+	}
+}
+
 func setupRouter() *gin.Engine {
 	r := gin.New()
 
-	r.Use(gin.Recovery(), middlewares.Logger()) // , middlewares.BasicAuth()
+	r.Use(gin.Recovery(), middlewares.RequestID(), middlewares.Logger(), middlewares.ErrorHandler(), middlewares.OptionalAuth()) // , middlewares.BasicAuth()
 
 	r.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	r.POST("/videos", func(context *gin.Context) {
+	r.POST("/videos", middlewares.AuthMiddleware(), handle(controller.VideoController.Save))
 
-		err := VideoController.Save(context)
-		if err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
-	})
+	r.POST("/signup", handle(controller.VideoController.SignUp))
 
-	r.POST("/signup", func(context *gin.Context) {
-		VideoController.SignUp(context)
-	})
+	r.POST("/login", handle(controller.VideoController.LogIn))
 
-	r.POST("/login", func(context *gin.Context) {
-		VideoController.LogIn(context)
-	})
+	r.POST("/token/refresh", middlewares.AuthMiddleware(), handle(controller.VideoController.RefreshToken))
 
-	r.GET("/videos", func(context *gin.Context) {
+	r.GET("/videos", handle(controller.VideoController.HandleVideoSearchAndPaginate))
 
-		err := VideoController.HandleVideoSearchAndPaginate(context)
-		if err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-	})
+	r.GET("/videos/all", handle(controller.VideoController.FindAll))
 
-	r.GET("/videos/all", func(context *gin.Context) {
-		err := VideoController.FindAll(context)
-		if err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-	})
+	r.GET("/videos/trash", handle(controller.VideoController.FindTrash))
 
-	r.GET("/videos/:id", func(context *gin.Context) {
-		err := VideoController.FindByID(context)
-		if err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
-	})
+	r.GET("/videos/suggest", handle(controller.VideoController.Suggest))
 
-	r.DELETE("/videos/:id", func(context *gin.Context) {
-		err := VideoController.Delete(context)
-		if err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
-	})
+	r.GET("/videos/captions", handle(controller.VideoController.SearchCaptions))
 
-	r.PATCH("/videos/:id", func(context *gin.Context) {
-		err := VideoController.Update(context)
-		if err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-	})
+	r.GET("/videos/:id", handle(controller.VideoController.FindByID))
+
+	r.DELETE("/videos/:id", middlewares.AuthMiddleware(), handle(controller.VideoController.Delete))
+
+	r.PATCH("/videos/:id", middlewares.AuthMiddleware(), handle(controller.VideoController.Update))
+
+	r.PUT("/videos/:id", middlewares.AuthMiddleware(), handle(controller.VideoController.Replace))
+
+	r.POST("/videos/:id/restore", middlewares.AuthMiddleware(), handle(controller.VideoController.Restore))
+
+	r.GET("/videos/:id/revisions", handle(controller.VideoController.FindRevisions))
+
+	r.POST("/videos/:id/revisions/:rev/revert", middlewares.AuthMiddleware(), handle(controller.VideoController.RevertToRevision))
+
+	r.POST("/videos/:id/media", middlewares.AuthMiddleware(), handle(controller.VideoController.UploadMedia))
+
+	r.GET("/videos/:id/media", handle(controller.VideoController.Stream))
+
+	r.GET("/videos/:id/stream", handle(controller.VideoController.Stream))
+
+	r.PUT("/videos/:id/renditions/:name", middlewares.AuthMiddleware(), handle(controller.VideoController.PutRendition))
+
+	r.GET("/videos/:id/hls/master.m3u8", handle(controller.VideoController.MasterPlaylist))
+
+	r.GET("/videos/:id/hls/:rendition/:file", handle(controller.VideoController.HLSFile))
+
+	r.POST("/videos/:id/thumbnail", middlewares.AuthMiddleware(), handle(controller.VideoController.UploadPoster))
+
+	r.GET("/videos/:id/thumbnail", handle(controller.VideoController.Thumbnail))
+
+	r.GET("/videos/:id/captions", handle(controller.VideoController.ListCaptions))
+
+	r.GET("/videos/:id/captions/:language", handle(controller.VideoController.GetCaptions))
+
+	r.PUT("/videos/:id/captions/:language", middlewares.AuthMiddleware(), handle(controller.VideoController.PutCaptions))
+
+	r.DELETE("/videos/:id/captions/:language", middlewares.AuthMiddleware(), handle(controller.VideoController.DeleteCaptions))
+
+	r.GET("/videos/:id/chapters", handle(controller.VideoController.ListChapters))
+
+	r.PUT("/videos/:id/chapters", middlewares.AuthMiddleware(), handle(controller.VideoController.PutChapters))
+
+	r.DELETE("/videos/:id/chapters", middlewares.AuthMiddleware(), handle(controller.VideoController.DeleteChapters))
+
+	r.OPTIONS("/uploads", handle(controller.VideoController.UploadOptions))
+
+	uploads := r.Group("/uploads", middlewares.AuthMiddleware())
+
+	uploads.POST("", handle(controller.VideoController.CreateUpload))
+
+	uploads.HEAD("/:id", handle(controller.VideoController.FindUpload))
+
+	uploads.PATCH("/:id", handle(controller.VideoController.WriteUpload))
+
+	uploads.DELETE("/:id", handle(controller.VideoController.DeleteUpload))
+
+	admin := r.Group("/admin", middlewares.AuthMiddleware(), middlewares.RequireRole(service.RoleAdmin))
+
+	admin.GET("/audit", handle(controller.VideoController.FindAuditEvents))
+
+	admin.GET("/jobs", handle(controller.VideoController.ListJobs))
+
+	admin.GET("/jobs/:id", handle(controller.VideoController.FindJob))
+
+	admin.POST("/jobs/:id/retry", handle(controller.VideoController.RetryJob))
+
+	admin.PUT("/users/:email/role", handle(controller.VideoController.SetUserRole))
 	return r
 }
 
@@ -126,6 +355,7 @@ func setupRouter() *gin.Engine {
 func main() {
 
 	setupLogOutput()
+	setupLimits()
 
 	client, err := setupMongoDB()
 	if err != nil {
@@ -135,8 +365,42 @@ func main() {
 
 	setupRedis()
 
-	videoService = service.NewMongoVideoService(client, "trungdb", "trungcl", "usercl", redisClient)
-	VideoController = controller.New(videoService)
+	revisions := service.NewMongoRevisionStore(client.Database("trungdb").Collection("revisioncl"))
+	blobs, err := setupBlobStore()
+	if err != nil {
+		panic(err)
+	}
+	uploads := service.NewMongoUploadStore(client.Database("trungdb").Collection("uploadcl"))
+	captions := service.NewMongoCaptionStore(client.Database("trungdb").Collection("captioncl"))
+	videoService = service.NewMongoVideoService(client, "trungdb", "trungcl", "usercl", redisClient, revisions, blobs, uploads, captions)
+	if err := videoService.Migrate(); err != nil {
+		panic(err)
+	}
+	if err := videoService.EnsureIndexes(); err != nil {
+		panic(err)
+	}
+
+	jobs := service.NewRedisJobQueue(redisClient)
+	pool := setupJobs(jobs)
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		// Only run jobs, finishing those in progress on SIGINT or SIGTERM
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		pool.Run(ctx)
+		return
+	}
+	if pool.Concurrency > 0 {
+		go pool.Run(context.Background())
+	}
+
+	if err := videoService.LoadSearchIndexes(); err != nil {
+		panic(err)
+	}
+	audit, err := setupAudit(client)
+	if err != nil {
+		panic(err)
+	}
+	VideoController = controller.New(videoService, audit, jobs)
 
 	server := setupRouter()
 